@@ -0,0 +1,36 @@
+package bunconf
+
+import (
+	"fmt"
+
+	"github.com/uptrace/uptrace/pkg/tracing"
+)
+
+// EnricherConfig enables a registered tracing.Enricher by name. Config is
+// enricher-specific and passed through as raw YAML for that enricher to
+// decode itself; bunconf doesn't know its shape.
+//
+// Enrichers turns a list of these into the specs tracing.BuildEnrichers
+// resolves. No config type in this package holds such a list; the caller
+// decodes the enrichers: section and passes it to Enrichers itself.
+type EnricherConfig struct {
+	Name   string                 `yaml:"name"`
+	Config map[string]interface{} `yaml:"config"`
+}
+
+func (c *EnricherConfig) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("enricher name is required")
+	}
+	return nil
+}
+
+// Enrichers converts a bunconf enrichers: section into the specs
+// tracing.BuildEnrichers expects, preserving order.
+func Enrichers(configs []EnricherConfig) []tracing.EnricherSpec {
+	specs := make([]tracing.EnricherSpec, len(configs))
+	for i, c := range configs {
+		specs[i] = tracing.EnricherSpec{Name: c.Name, Config: c.Config}
+	}
+	return specs
+}
@@ -1,13 +1,47 @@
 package bunconf
 
 import (
+	"context"
 	"fmt"
+	"text/template"
 	"time"
 
+	"github.com/uptrace/uptrace/pkg/metrics"
 	"github.com/uptrace/uptrace/pkg/metrics/alerting"
 	"github.com/uptrace/uptrace/pkg/metrics/upql"
 )
 
+// templatePreamble binds $labels and $value before a rule's own template
+// text runs, so annotations/labels can be written Prometheus-style as
+// `{{ $value }}` or `{{ $labels.host }}` instead of `{{ .Value }}`.
+const templatePreamble = `{{$labels := .Labels}}{{$value := .Value}}`
+
+var templateFuncs = template.FuncMap{
+	"humanize":         humanizeNumber,
+	"humanizeDuration": humanizeDuration,
+	"printf":           fmt.Sprintf,
+}
+
+// parseTemplates compiles each value in fields as a Go template, returning a
+// map keyed the same way. It is used for both AlertRule.Labels and
+// AlertRule.Annotations so a malformed template fails config load instead of
+// alert fire time.
+func parseTemplates(prefix string, fields map[string]string) (map[string]*template.Template, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	compiled := make(map[string]*template.Template, len(fields))
+	for name, text := range fields {
+		tmpl, err := template.New(prefix + "." + name).Funcs(templateFuncs).Parse(templatePreamble + text)
+		if err != nil {
+			return nil, fmt.Errorf("parsing template %s.%s: %w", prefix, name, err)
+		}
+		compiled[name] = tmpl
+	}
+	return compiled, nil
+}
+
 type Dashboard struct {
 	ID      string                   `yaml:"id"`
 	Name    string                   `yaml:"name"`
@@ -49,6 +83,8 @@ type DashEntry struct {
 	Metrics []string                 `yaml:"metrics"`
 	Query   string                   `yaml:"query"`
 	Columns map[string]*MetricColumn `yaml:"columns"`
+
+	metrics []upql.Metric
 }
 
 func (e *DashEntry) Validate() error {
@@ -61,23 +97,95 @@ func (e *DashEntry) Validate() error {
 	if e.Query == "" {
 		return fmt.Errorf("entry query is required")
 	}
+
+	parsed, err := upql.ParseMetrics(e.Metrics)
+	if err != nil {
+		return err
+	}
+	e.metrics = parsed
+
 	return nil
 }
 
+// Plan builds a metrics.RequestPlan describing the sub-queries needed to
+// render this entry over window, so dashboard rendering can fan out tier2
+// workers the same way alerting.Planner does for rules.
+func (e *DashEntry) Plan(ctx context.Context, planner *metrics.Planner, window time.Duration) (*metrics.RequestPlan, error) {
+	refs := make([]metrics.MetricRef, len(e.metrics))
+	for i, m := range e.metrics {
+		refs[i] = metrics.MetricRef{Name: m.Name, Metric: m}
+	}
+
+	now := time.Now()
+	timeRange := metrics.TimeRange{Start: now.Add(-window), End: now}
+
+	return planner.Plan(ctx, refs, timeRange)
+}
+
 type MetricColumn struct {
 	Unit string `yaml:"unit" json:"unit"`
 }
 
+// RouteConfig matches a firing alert's labels and routes it to a set of
+// notification channels, Alertmanager-style.
+type RouteConfig struct {
+	Match         map[string]string `yaml:"match"`
+	Channels      []ChannelConfig   `yaml:"channels"`
+	Inhibit       bool              `yaml:"inhibit"`
+	GroupBy       []string          `yaml:"group_by"`
+	GroupWait     time.Duration     `yaml:"group_wait"`
+	GroupInterval time.Duration     `yaml:"group_interval"`
+}
+
+func (r *RouteConfig) Validate() error {
+	if len(r.Channels) == 0 {
+		return fmt.Errorf("route requires at least one channel")
+	}
+	for _, ch := range r.Channels {
+		if err := ch.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ChannelConfig is a single notification target (Slack, webhook, email, or
+// PagerDuty) a RouteConfig can dispatch a firing alert to.
+type ChannelConfig struct {
+	Kind   string `yaml:"kind"`
+	Target string `yaml:"target"`
+}
+
+func (c *ChannelConfig) Validate() error {
+	switch c.Kind {
+	case "slack", "webhook", "email", "pagerduty":
+	default:
+		return fmt.Errorf("unknown channel kind %q", c.Kind)
+	}
+	if c.Target == "" {
+		return fmt.Errorf("channel %q requires a target", c.Kind)
+	}
+	return nil
+}
+
 type AlertRule struct {
-	Name        string            `yaml:"name"`
-	Metrics     []string          `yaml:"metrics"`
-	Expr        string            `yaml:"expr"`
-	For         time.Duration     `yaml:"for"`
-	Labels      map[string]string `yaml:"labels"`
-	Annotations map[string]string `yaml:"annotations"`
-	Projects    []uint32          `yaml:"projects"`
+	Name    string        `yaml:"name"`
+	Metrics []string      `yaml:"metrics"`
+	Expr    string        `yaml:"expr"`
+	// For is how long Expr must hold before the rule fires. "0s" fires
+	// immediately on the first match, same as Alertmanager's `for: 0s`.
+	For time.Duration `yaml:"for"`
+	// KeepFiringFor keeps an already-firing alert in the firing state for
+	// this long after Expr stops matching, to absorb flapping.
+	KeepFiringFor time.Duration     `yaml:"keep_firing_for"`
+	Labels        map[string]string `yaml:"labels"`
+	Annotations   map[string]string `yaml:"annotations"`
+	Projects      []uint32          `yaml:"projects"`
+	Routes        []RouteConfig     `yaml:"routes"`
 
-	metrics []upql.Metric
+	metrics             []upql.Metric
+	labelTemplates      map[string]*template.Template
+	annotationTemplates map[string]*template.Template
 }
 
 func (r *AlertRule) Validate() error {
@@ -95,11 +203,11 @@ func (r *AlertRule) validate() error {
 		return fmt.Errorf("at least one metric is required")
 	}
 
-	metrics, err := upql.ParseMetrics(r.Metrics)
+	parsed, err := upql.ParseMetrics(r.Metrics)
 	if err != nil {
 		return err
 	}
-	r.metrics = metrics
+	r.metrics = parsed
 
 	if r.Expr == "" {
 		return fmt.Errorf("rule expr is required")
@@ -107,16 +215,100 @@ func (r *AlertRule) validate() error {
 	if len(r.Projects) == 0 {
 		return fmt.Errorf("at least on project is required")
 	}
+	if r.KeepFiringFor < 0 {
+		return fmt.Errorf("keep_firing_for must not be negative")
+	}
+
+	labelTemplates, err := parseTemplates("labels", r.Labels)
+	if err != nil {
+		return err
+	}
+	r.labelTemplates = labelTemplates
+
+	annotationTemplates, err := parseTemplates("annotations", r.Annotations)
+	if err != nil {
+		return err
+	}
+	r.annotationTemplates = annotationTemplates
+
+	for i := range r.Routes {
+		if err := r.Routes[i].Validate(); err != nil {
+			return fmt.Errorf("route %d: %w", i, err)
+		}
+	}
+
 	return nil
 }
 
 func (r *AlertRule) RuleConfig() alerting.RuleConfig {
+	routes := make([]alerting.RouteConfig, len(r.Routes))
+	for i, route := range r.Routes {
+		channels := make([]alerting.ChannelConfig, len(route.Channels))
+		for j, ch := range route.Channels {
+			channels[j] = alerting.ChannelConfig{
+				Kind:   alerting.ChannelKind(ch.Kind),
+				Target: ch.Target,
+			}
+		}
+		routes[i] = alerting.RouteConfig{
+			Match:         route.Match,
+			Channels:      channels,
+			Inhibit:       route.Inhibit,
+			GroupBy:       route.GroupBy,
+			GroupWait:     route.GroupWait,
+			GroupInterval: route.GroupInterval,
+		}
+	}
+
 	return alerting.RuleConfig{
-		Name:        r.Name,
-		Metrics:     r.metrics,
-		Expr:        r.Expr,
-		For:         r.For,
-		Labels:      r.Labels,
-		Annotations: r.Annotations,
+		Name:                r.Name,
+		Metrics:             r.metrics,
+		Expr:                r.Expr,
+		For:                 r.For,
+		KeepFiringFor:       r.KeepFiringFor,
+		Labels:              r.Labels,
+		Annotations:         r.Annotations,
+		LabelTemplates:      r.labelTemplates,
+		AnnotationTemplates: r.annotationTemplates,
+		Routes:              routes,
+	}
+}
+
+// Plan builds a metrics.RequestPlan describing the sub-queries needed to
+// evaluate this rule over window, via the shared alerting/metrics Planner.
+func (r *AlertRule) Plan(ctx context.Context, planner *alerting.Planner, window time.Duration) (*metrics.RequestPlan, error) {
+	return planner.Plan(ctx, r.RuleConfig(), window)
+}
+
+// humanizeNumber renders a float the way Prometheus's `humanize` template
+// func does: scaled by the nearest SI-ish suffix (k, M, G, ...) to keep
+// alert text readable.
+func humanizeNumber(v float64) string {
+	if v == 0 {
+		return "0"
+	}
+
+	suffixes := []string{"", "k", "M", "G", "T", "P", "E"}
+	sign := ""
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+
+	i := 0
+	for v >= 1000 && i < len(suffixes)-1 {
+		v /= 1000
+		i++
+	}
+	return fmt.Sprintf("%s%.3g%s", sign, v, suffixes[i])
+}
+
+// humanizeDuration renders d for the `humanizeDuration` template func:
+// durations under a second as whole milliseconds (e.g. "500ms"), longer ones
+// rounded to the second in Go's Duration format (e.g. 90m -> "1h30m0s").
+func humanizeDuration(d time.Duration) string {
+	if d < time.Second {
+		return fmt.Sprintf("%dms", d.Milliseconds())
 	}
+	return d.Round(time.Second).String()
 }
@@ -0,0 +1,85 @@
+package bunconf
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uptrace/uptrace/pkg/metrics/alerting"
+)
+
+func TestHumanizeNumber(t *testing.T) {
+	cases := []struct {
+		in   float64
+		want string
+	}{
+		{0, "0"},
+		{1, "1"},
+		{999, "999"},
+		{1000, "1k"},
+		{1500, "1.5k"},
+		{1000000, "1M"},
+		{-2000, "-2k"},
+	}
+	for _, c := range cases {
+		if got := humanizeNumber(c.in); got != c.want {
+			t.Errorf("humanizeNumber(%v) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestHumanizeDuration(t *testing.T) {
+	cases := []struct {
+		in   time.Duration
+		want string
+	}{
+		{500 * time.Millisecond, "500ms"},
+		{90 * time.Minute, "1h30m0s"},
+		{0, "0ms"},
+	}
+	for _, c := range cases {
+		if got := humanizeDuration(c.in); got != c.want {
+			t.Errorf("humanizeDuration(%v) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestParseTemplatesEmpty(t *testing.T) {
+	compiled, err := parseTemplates("labels", nil)
+	if err != nil {
+		t.Fatalf("parseTemplates(nil): %v", err)
+	}
+	if compiled != nil {
+		t.Fatalf("parseTemplates(nil) = %v, want nil", compiled)
+	}
+}
+
+func TestParseTemplatesCompilesAndExecutes(t *testing.T) {
+	fields := map[string]string{
+		"summary": "{{ $labels.host }} is at {{ humanize $value }}",
+	}
+	compiled, err := parseTemplates("labels", fields)
+	if err != nil {
+		t.Fatalf("parseTemplates: %v", err)
+	}
+	tmpl, ok := compiled["summary"]
+	if !ok {
+		t.Fatal("compiled templates missing \"summary\"")
+	}
+
+	var b strings.Builder
+	data := alerting.TemplateData{Labels: map[string]string{"host": "web-1"}, Value: 2500}
+	if err := tmpl.Execute(&b, data); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if want := "web-1 is at 2.5k"; b.String() != want {
+		t.Fatalf("Execute output = %q, want %q", b.String(), want)
+	}
+}
+
+func TestParseTemplatesRejectsMalformed(t *testing.T) {
+	_, err := parseTemplates("labels", map[string]string{"bad": "{{ .Unclosed"})
+	if err == nil {
+		t.Fatal("parseTemplates with malformed template text returned nil error")
+	}
+}
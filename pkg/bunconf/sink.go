@@ -0,0 +1,122 @@
+package bunconf
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/uptrace/uptrace/pkg/tracing"
+)
+
+// SinkKind selects which Sink implementation backs a project's ingestion
+// pipeline.
+type SinkKind string
+
+const (
+	SinkMemory SinkKind = "memory"
+	SinkWAL    SinkKind = "wal"
+	SinkKafka  SinkKind = "kafka"
+	SinkPulsar SinkKind = "pulsar"
+)
+
+// SinkConfig configures the durability behavior of the ingestion pipeline.
+// NewSink builds one Sink from one SinkConfig; this package has no per-project
+// table of SinkConfigs and applies no top-level default to them.
+type SinkConfig struct {
+	Kind SinkKind `yaml:"kind"`
+
+	BufferSize int `yaml:"buffer_size"`
+
+	// AddTimeout bounds how long AddItem blocks waiting for room in the
+	// sink before giving up.
+	AddTimeout time.Duration `yaml:"add_timeout"`
+
+	// ShutdownTimeout bounds how long Processor.Close waits for a graceful
+	// drain before spilling whatever's left in the queue to SpillDir.
+	// Defaults to 30s.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+
+	// SpillDir is where a shutdown drain that hits ShutdownTimeout persists
+	// remaining items for replay on the next start. Point it at WAL.Dir to
+	// have the WAL sink pick them up automatically; left empty, those
+	// items are logged and dropped.
+	SpillDir string `yaml:"spill_dir"`
+
+	// WAL is used when Kind is "wal".
+	WAL WALConfig `yaml:"wal"`
+
+	// Topic is used when Kind is "kafka" or "pulsar".
+	Topic string `yaml:"topic"`
+}
+
+// WALConfig configures the on-disk write-ahead log sink.
+type WALConfig struct {
+	Dir           string        `yaml:"dir"`
+	SegmentBytes  int64         `yaml:"segment_bytes"`
+	FsyncPolicy   string        `yaml:"fsync_policy"` // "always" | "interval"
+	FsyncInterval time.Duration `yaml:"fsync_interval"`
+}
+
+// fsyncPolicy resolves the YAML "always"/"interval" string to the
+// tracing.FsyncPolicy NewSink passes to tracing.NewWALSink.
+func (c *WALConfig) fsyncPolicy() tracing.FsyncPolicy {
+	if c.FsyncPolicy == "interval" {
+		return tracing.FsyncInterval
+	}
+	return tracing.FsyncAlways
+}
+
+func (c *SinkConfig) Validate() error {
+	switch c.Kind {
+	case "", SinkMemory:
+	case SinkWAL:
+		if c.WAL.Dir == "" {
+			return fmt.Errorf("sink kind %q requires wal.dir", c.Kind)
+		}
+	case SinkKafka, SinkPulsar:
+		if c.Topic == "" {
+			return fmt.Errorf("sink kind %q requires topic", c.Kind)
+		}
+	default:
+		return fmt.Errorf("unknown sink kind %q", c.Kind)
+	}
+
+	if c.AddTimeout < 0 {
+		return fmt.Errorf("sink add_timeout must not be negative")
+	}
+	if c.ShutdownTimeout < 0 {
+		return fmt.Errorf("sink shutdown_timeout must not be negative")
+	}
+
+	return nil
+}
+
+// NewSink turns a validated SinkConfig into the tracing.Sink it describes.
+// pub is only used when c.Kind is "kafka" or "pulsar"; operators construct
+// the actual broker client and pass it in, since bunconf doesn't take a
+// dependency on either wire protocol.
+func NewSink[T any](c *SinkConfig, logger *zap.Logger, pub tracing.Publisher) (tracing.Sink[T], error) {
+	switch c.Kind {
+	case "", SinkMemory:
+		return tracing.NewMemorySink[T](c.BufferSize), nil
+	case SinkWAL:
+		return tracing.NewWALSink[T](tracing.WALConfig{
+			Dir:           c.WAL.Dir,
+			SegmentBytes:  c.WAL.SegmentBytes,
+			FsyncPolicy:   c.WAL.fsyncPolicy(),
+			FsyncInterval: c.WAL.FsyncInterval,
+			BufferSize:    c.BufferSize,
+		}, logger)
+	case SinkKafka, SinkPulsar:
+		if pub == nil {
+			return nil, fmt.Errorf("sink kind %q requires a tracing.Publisher", c.Kind)
+		}
+		return tracing.NewStreamSink[T](tracing.StreamConfig{
+			Topic:      c.Topic,
+			BufferSize: c.BufferSize,
+		}, pub), nil
+	default:
+		return nil, fmt.Errorf("unknown sink kind %q", c.Kind)
+	}
+}
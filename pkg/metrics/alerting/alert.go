@@ -0,0 +1,76 @@
+package alerting
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+	"time"
+)
+
+// TemplateData is what a RuleConfig's compiled LabelTemplates and
+// AnnotationTemplates execute against. Field names match the templatePreamble
+// bindings in bunconf ($labels -> .Labels, $value -> .Value) so a rule's YAML
+// can use either form.
+type TemplateData struct {
+	Labels map[string]string
+	Value  float64
+}
+
+// Alert is a single firing instance of a RuleConfig: the triggering series'
+// labels plus its rendered label/annotation templates, ready to hand to
+// Dispatch.
+type Alert struct {
+	RuleName    string
+	Labels      map[string]string
+	Annotations map[string]string
+	Value       float64
+	FiredAt     time.Time
+}
+
+// Render evaluates r's compiled label and annotation templates against the
+// series that tripped the rule, returning a ready-to-dispatch Alert.
+// seriesLabels becomes both the alert's own Labels (merged with r.Labels'
+// rendered values) and the $labels a template can reference.
+func (r *RuleConfig) Render(seriesLabels map[string]string, value float64, firedAt time.Time) (*Alert, error) {
+	data := TemplateData{Labels: seriesLabels, Value: value}
+
+	labels := make(map[string]string, len(seriesLabels)+len(r.LabelTemplates))
+	for k, v := range seriesLabels {
+		labels[k] = v
+	}
+	for name, tmpl := range r.LabelTemplates {
+		rendered, err := execTemplate(tmpl, data)
+		if err != nil {
+			return nil, fmt.Errorf("alerting: rendering label %q: %w", name, err)
+		}
+		labels[name] = rendered
+	}
+
+	var annotations map[string]string
+	if len(r.AnnotationTemplates) > 0 {
+		annotations = make(map[string]string, len(r.AnnotationTemplates))
+		for name, tmpl := range r.AnnotationTemplates {
+			rendered, err := execTemplate(tmpl, data)
+			if err != nil {
+				return nil, fmt.Errorf("alerting: rendering annotation %q: %w", name, err)
+			}
+			annotations[name] = rendered
+		}
+	}
+
+	return &Alert{
+		RuleName:    r.Name,
+		Labels:      labels,
+		Annotations: annotations,
+		Value:       value,
+		FiredAt:     firedAt,
+	}, nil
+}
+
+func execTemplate(tmpl *template.Template, data TemplateData) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,43 @@
+package alerting
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/uptrace/pkg/metrics"
+)
+
+// Planner builds a metrics.RequestPlan for a rule's sub-queries and dispatches
+// tier2 workers to evaluate it, following the same tier1/tier2 split used for
+// dashboards so rule evaluation and dashboard rendering share one executor.
+type Planner struct {
+	metrics *metrics.Planner
+}
+
+// NewPlanner returns a Planner that spreads tier2 work for a rule across
+// shards parallel shards.
+func NewPlanner(shards int) *Planner {
+	return &Planner{metrics: metrics.NewPlanner(shards)}
+}
+
+// Plan builds a RequestPlan from rule's already-parsed metrics describing
+// which sub-queries can run in parallel over window, marking Cached any
+// task whose window the partial-range cache already covers.
+func (p *Planner) Plan(ctx context.Context, rule RuleConfig, window time.Duration) (*metrics.RequestPlan, error) {
+	refs := make([]metrics.MetricRef, len(rule.Metrics))
+	for i, m := range rule.Metrics {
+		refs[i] = metrics.MetricRef{Name: m.Name, Metric: m}
+	}
+
+	now := time.Now()
+	timeRange := metrics.TimeRange{Start: now.Add(-window), End: now}
+
+	return p.metrics.Plan(ctx, refs, timeRange)
+}
+
+// Execute runs exec over every task in plan in parallel, skipping tasks
+// already satisfied by the partial-range cache, and blocks until every
+// task's goroutine has returned (see metrics.Planner.Execute).
+func (p *Planner) Execute(ctx context.Context, plan *metrics.RequestPlan, exec func(context.Context, *metrics.PlanTask) (interface{}, error)) []metrics.Result {
+	return p.metrics.Execute(ctx, plan, exec)
+}
@@ -0,0 +1,230 @@
+package alerting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RouteConfig matches a firing alert's labels against Match and, on a hit,
+// notifies Channels. Routes mirror Alertmanager's matcher/group model so
+// multi-tenant rules can fan a single alert out to the right Slack channel,
+// webhook, email, or PagerDuty target.
+type RouteConfig struct {
+	// Match is an exact label-equality matcher: every key/value pair
+	// must be present on the firing alert's labels for the route to
+	// apply.
+	Match map[string]string
+
+	Channels []ChannelConfig
+
+	// Inhibit suppresses this route's notifications while a
+	// higher-priority route already matched the same labels.
+	Inhibit bool
+
+	GroupBy       []string
+	GroupWait     time.Duration
+	GroupInterval time.Duration
+}
+
+// ChannelKind identifies the notification transport a ChannelConfig targets.
+type ChannelKind string
+
+const (
+	ChannelSlack     ChannelKind = "slack"
+	ChannelWebhook   ChannelKind = "webhook"
+	ChannelEmail     ChannelKind = "email"
+	ChannelPagerDuty ChannelKind = "pagerduty"
+)
+
+// ChannelConfig is a single notification target a RouteConfig dispatches to.
+type ChannelConfig struct {
+	Kind   ChannelKind
+	Target string
+}
+
+// Matches reports whether labels satisfies every matcher in r.Match.
+func (r *RouteConfig) Matches(labels map[string]string) bool {
+	for k, v := range r.Match {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Notifier delivers a batch of alerts belonging to the same group to a
+// single channel. Operators plug in a real Slack/webhook/email/PagerDuty
+// client by implementing this interface, the same way tracing.Publisher
+// abstracts the broker client for a StreamSink.
+type Notifier interface {
+	Notify(ctx context.Context, channel ChannelConfig, alerts []*Alert) error
+}
+
+// Dispatch routes alert through routes in order, notifying every channel of
+// each matching route via notifier, except routes whose Inhibit is set and
+// which match after an earlier route already matched the same labels (per
+// RouteConfig.Inhibit). Grouping (GroupBy/GroupWait/GroupInterval) is
+// applied per matching route via grouper before a channel is notified.
+func Dispatch(ctx context.Context, alert *Alert, routes []RouteConfig, grouper *Grouper, notifier Notifier) error {
+	matched := false
+	var errs []error
+
+	for i := range routes {
+		route := &routes[i]
+		if !route.Matches(alert.Labels) {
+			continue
+		}
+		if route.Inhibit && matched {
+			continue
+		}
+		matched = true
+
+		if err := grouper.Add(ctx, route, alert, notifier); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// groupKey identifies an alert group within a route: the values of
+// GroupBy's labels for a given alert. An empty GroupBy collapses every
+// alert for the route into a single group.
+func groupKey(labels map[string]string, groupBy []string) string {
+	if len(groupBy) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, k := range groupBy {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(labels[k])
+		b.WriteByte(';')
+	}
+	return b.String()
+}
+
+// group accumulates alerts for one route+groupKey pair between flushes.
+// scheduled is true for exactly as long as a flushLoop goroutine owns this
+// group; both it and alerts are guarded by Grouper.mu, not a lock of their
+// own, so that "is a flush already scheduled" and "append this alert" are
+// always one atomic step — see Grouper.Add.
+type group struct {
+	alerts    []*Alert
+	scheduled bool
+}
+
+// Grouper batches alerts for the same route and GroupBy key together,
+// notifying each route's channels at most once per GroupWait (for a group's
+// first alert) or GroupInterval (for any that follow), mirroring
+// Alertmanager's grouping model. The zero value groups nothing: every Add
+// notifies immediately with a single-alert batch.
+type Grouper struct {
+	mu     sync.Mutex
+	groups map[string]*group
+}
+
+// NewGrouper returns a ready-to-use Grouper.
+func NewGrouper() *Grouper {
+	return &Grouper{groups: make(map[string]*group)}
+}
+
+// Add enqueues alert into the group route and its GroupBy key identify. If
+// no flush is currently scheduled for that group it starts one (spawning
+// flushLoop, which owns the group until it has nothing left to flush);
+// otherwise the alert is folded into the flush already pending. Checking
+// and setting "scheduled" happens under the same lock as the append so two
+// concurrent Adds for the same key can never both conclude a flush needs
+// scheduling.
+func (g *Grouper) Add(ctx context.Context, route *RouteConfig, alert *Alert, notifier Notifier) error {
+	if g == nil || notifier == nil {
+		return nil
+	}
+
+	if g.groups == nil || (len(route.GroupBy) == 0 && route.GroupWait <= 0) {
+		return g.notify(ctx, route, []*Alert{alert}, notifier)
+	}
+
+	key := fmt.Sprintf("%p:%s", route, groupKey(alert.Labels, route.GroupBy))
+
+	g.mu.Lock()
+	grp, ok := g.groups[key]
+	if !ok {
+		grp = &group{}
+		g.groups[key] = grp
+	}
+	grp.alerts = append(grp.alerts, alert)
+	alreadyScheduled := grp.scheduled
+	grp.scheduled = true
+	g.mu.Unlock()
+
+	if alreadyScheduled {
+		return nil
+	}
+
+	go g.flushLoop(ctx, key, route, grp, notifier)
+	return nil
+}
+
+// flushLoop waits GroupWait, flushes whatever accumulated in grp, and if
+// more alerts arrived during that flush keeps going — waiting
+// GroupInterval and flushing again — for as long as that keeps being true.
+// grp.scheduled is only cleared, and grp removed from g.groups, in the same
+// critical section where this loop decides to stop, so a concurrent Add
+// can never observe a group as unscheduled while this goroutine still
+// intends to flush it (or vice versa spawn a second flushLoop for it).
+func (g *Grouper) flushLoop(ctx context.Context, key string, route *RouteConfig, grp *group, notifier Notifier) {
+	wait := route.GroupWait
+	for {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+
+		g.mu.Lock()
+		alerts := grp.alerts
+		grp.alerts = nil
+		g.mu.Unlock()
+
+		if len(alerts) > 0 {
+			if err := g.notify(ctx, route, alerts, notifier); err != nil {
+				// Dispatch's caller only observes errors from the initial,
+				// synchronous Add; a grouped flush happens on its own
+				// goroutine, so there's nowhere left to surface this but
+				// the log a real Notifier implementation will have done
+				// its own logging of the delivery failure.
+				_ = err
+			}
+		}
+
+		g.mu.Lock()
+		keepGoing := len(grp.alerts) > 0
+		if !keepGoing {
+			grp.scheduled = false
+			delete(g.groups, key)
+		}
+		g.mu.Unlock()
+
+		if !keepGoing {
+			return
+		}
+		wait = route.GroupInterval
+	}
+}
+
+func (g *Grouper) notify(ctx context.Context, route *RouteConfig, alerts []*Alert, notifier Notifier) error {
+	var errs []error
+	for _, ch := range route.Channels {
+		if err := notifier.Notify(ctx, ch, alerts); err != nil {
+			errs = append(errs, fmt.Errorf("channel %s %s: %w", ch.Kind, ch.Target, err))
+		}
+	}
+	return errors.Join(errs...)
+}
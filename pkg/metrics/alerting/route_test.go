@@ -0,0 +1,173 @@
+package alerting
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRouteConfigMatches(t *testing.T) {
+	route := &RouteConfig{Match: map[string]string{"env": "prod", "service": "api"}}
+
+	cases := []struct {
+		name   string
+		labels map[string]string
+		want   bool
+	}{
+		{"exact match", map[string]string{"env": "prod", "service": "api"}, true},
+		{"superset still matches", map[string]string{"env": "prod", "service": "api", "region": "us"}, true},
+		{"missing key", map[string]string{"env": "prod"}, false},
+		{"wrong value", map[string]string{"env": "staging", "service": "api"}, false},
+		{"empty labels", map[string]string{}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := route.Matches(c.labels); got != c.want {
+				t.Errorf("Matches(%v) = %v, want %v", c.labels, got, c.want)
+			}
+		})
+	}
+}
+
+func TestRouteConfigMatchesEmptyMatcherMatchesEverything(t *testing.T) {
+	route := &RouteConfig{}
+	if !route.Matches(map[string]string{"anything": "goes"}) {
+		t.Fatal("Matches with an empty Match should match any labels")
+	}
+}
+
+func TestGroupKey(t *testing.T) {
+	labels := map[string]string{"env": "prod", "service": "api"}
+
+	if got := groupKey(labels, nil); got != "" {
+		t.Errorf("groupKey with no GroupBy = %q, want empty string", got)
+	}
+
+	k1 := groupKey(labels, []string{"env", "service"})
+	k2 := groupKey(map[string]string{"env": "prod", "service": "api", "region": "us"}, []string{"env", "service"})
+	if k1 != k2 {
+		t.Errorf("groupKey ignoring an extra label should match: %q != %q", k1, k2)
+	}
+
+	k3 := groupKey(map[string]string{"env": "staging", "service": "api"}, []string{"env", "service"})
+	if k1 == k3 {
+		t.Errorf("groupKey for different env values should differ, both = %q", k1)
+	}
+}
+
+type recordingNotifier struct {
+	mu    sync.Mutex
+	calls [][]*Alert
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, channel ChannelConfig, alerts []*Alert) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	batch := make([]*Alert, len(alerts))
+	copy(batch, alerts)
+	n.calls = append(n.calls, batch)
+	return nil
+}
+
+func (n *recordingNotifier) snapshot() [][]*Alert {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	out := make([][]*Alert, len(n.calls))
+	copy(out, n.calls)
+	return out
+}
+
+func TestGrouperBatchesAlertsWithinGroupWait(t *testing.T) {
+	g := NewGrouper()
+	notifier := &recordingNotifier{}
+	route := &RouteConfig{
+		Channels:  []ChannelConfig{{Kind: ChannelSlack, Target: "#alerts"}},
+		GroupBy:   []string{"env"},
+		GroupWait: 50 * time.Millisecond,
+	}
+
+	for i := 0; i < 5; i++ {
+		alert := &Alert{Labels: map[string]string{"env": "prod"}}
+		if err := g.Add(context.Background(), route, alert, notifier); err != nil {
+			t.Fatalf("Add(%d): %v", i, err)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(notifier.snapshot()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	calls := notifier.snapshot()
+	if len(calls) != 1 {
+		t.Fatalf("got %d notify calls, want exactly 1 batched call", len(calls))
+	}
+	if len(calls[0]) != 5 {
+		t.Fatalf("batched call carried %d alerts, want 5", len(calls[0]))
+	}
+}
+
+// TestGrouperConcurrentAddDoesNotDoubleSchedule fires many concurrent Adds
+// for the same group key and checks that exactly one flush goes out with
+// every alert folded into it, instead of two overlapping flushes each
+// claiming a subset — the race the scheduled flag in group was added to
+// close.
+func TestGrouperConcurrentAddDoesNotDoubleSchedule(t *testing.T) {
+	g := NewGrouper()
+	notifier := &recordingNotifier{}
+	route := &RouteConfig{
+		Channels:  []ChannelConfig{{Kind: ChannelWebhook, Target: "http://example.invalid"}},
+		GroupBy:   []string{"env"},
+		GroupWait: 50 * time.Millisecond,
+	}
+
+	const n = 50
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			alert := &Alert{Labels: map[string]string{"env": "prod"}}
+			if err := g.Add(context.Background(), route, alert, notifier); err != nil {
+				t.Errorf("Add: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if calls := notifier.snapshot(); len(calls) > 0 {
+			total := 0
+			for _, c := range calls {
+				total += len(c)
+			}
+			if total == n {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	calls := notifier.snapshot()
+	if len(calls) != 1 {
+		t.Fatalf("got %d notify calls for one group, want exactly 1; concurrent Adds spawned duplicate flushes", len(calls))
+	}
+	if len(calls[0]) != n {
+		t.Fatalf("batched call carried %d alerts, want %d", len(calls[0]), n)
+	}
+}
+
+func TestGrouperNilIsNoOp(t *testing.T) {
+	var g *Grouper
+	notifier := &recordingNotifier{}
+	route := &RouteConfig{Channels: []ChannelConfig{{Kind: ChannelEmail, Target: "ops@example.com"}}}
+
+	if err := g.Add(context.Background(), route, &Alert{}, notifier); err != nil {
+		t.Fatalf("Add on nil Grouper: %v", err)
+	}
+	if len(notifier.snapshot()) != 0 {
+		t.Fatal("nil Grouper should not notify")
+	}
+}
@@ -0,0 +1,35 @@
+package alerting
+
+import (
+	"text/template"
+	"time"
+
+	"github.com/uptrace/uptrace/pkg/metrics/upql"
+)
+
+// RuleConfig is the compiled, runtime representation of a bunconf.AlertRule:
+// parsed metrics, pre-compiled annotation/label templates, and a resolved
+// route tree, so the evaluator never has to touch YAML or re-parse a
+// template on the hot path.
+type RuleConfig struct {
+	Name    string
+	Metrics []upql.Metric
+	Expr    string
+
+	For time.Duration
+	// KeepFiringFor keeps an alert in the firing state for this long
+	// after its expression stops matching, to absorb flapping.
+	KeepFiringFor time.Duration
+
+	Labels      map[string]string
+	Annotations map[string]string
+
+	// LabelTemplates and AnnotationTemplates hold the compiled
+	// text/template for each entry in Labels/Annotations, keyed the same
+	// way. They are evaluated at fire time against the triggering
+	// series' $labels and $value.
+	LabelTemplates      map[string]*template.Template
+	AnnotationTemplates map[string]*template.Template
+
+	Routes []RouteConfig
+}
@@ -0,0 +1,195 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/uptrace/uptrace/pkg/metrics/upql"
+)
+
+// TimeRange is the half-open window [Start, End) a PlanTask evaluates over.
+type TimeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// MetricRef pairs a metric's configured name with its parsed upql.Metric, so
+// the planner can cache and log against a stable string key without needing
+// upql.Metric itself to be hashable.
+type MetricRef struct {
+	Name   string
+	Metric upql.Metric
+}
+
+// PlanTask is one sub-query a tier2 worker executes: a single metric over a
+// single time range on a single shard.
+type PlanTask struct {
+	Ref   MetricRef
+	Range TimeRange
+	Shard int
+
+	// Cached is set by Planner.Plan when an already-computed window
+	// covers this task; tier2 workers should skip it and reuse the
+	// cached result instead of hitting ClickHouse again.
+	Cached bool
+}
+
+// RequestPlan describes how to evaluate a set of metrics over a time range:
+// which sub-queries (PlanTask) can run in parallel, and which of those are
+// already satisfied by the partial-range cache.
+type RequestPlan struct {
+	Refs  []MetricRef
+	Range TimeRange
+	Tasks []*PlanTask
+}
+
+// rangeCache remembers which [metric, shard] windows have already been
+// computed, so a later overlapping Plan can skip re-querying them, and
+// holds the value computed for each so a skipped task still has something
+// real to hand back instead of a discarded result.
+type rangeCache struct {
+	mu       sync.Mutex
+	computed map[string]TimeRange
+	values   map[string]interface{}
+}
+
+func newRangeCache() *rangeCache {
+	return &rangeCache{
+		computed: make(map[string]TimeRange),
+		values:   make(map[string]interface{}),
+	}
+}
+
+func cacheKey(name string, shard int) string {
+	return fmt.Sprintf("%s#%d", name, shard)
+}
+
+func (c *rangeCache) covers(name string, shard int, r TimeRange) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.computed[cacheKey(name, shard)]
+	if !ok {
+		return false
+	}
+	return !r.Start.Before(cached.Start) && !r.End.After(cached.End)
+}
+
+// record extends the covered range for [name, shard] and stores value as
+// what a later Cached task for it should reuse. value represents the whole
+// newly covered range, not just r, so it replaces rather than merges with
+// whatever was previously cached.
+func (c *rangeCache) record(name string, shard int, r TimeRange, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := cacheKey(name, shard)
+	if existing, ok := c.computed[key]; ok {
+		if r.Start.Before(existing.Start) {
+			existing.Start = r.Start
+		}
+		if r.End.After(existing.End) {
+			existing.End = r.End
+		}
+		c.computed[key] = existing
+	} else {
+		c.computed[key] = r
+	}
+	c.values[key] = value
+}
+
+// value returns whatever was last recorded for [name, shard], or nil if
+// nothing has been.
+func (c *rangeCache) value(name string, shard int) interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[cacheKey(name, shard)]
+}
+
+// Planner turns a set of metrics plus a time range into a RequestPlan and
+// fans tier2 workers out across it. alerting.Planner and dashboard rendering
+// both build on the same Planner so rule evaluation and dashboard rendering
+// share one parallel executor.
+type Planner struct {
+	shards int
+	cache  *rangeCache
+}
+
+// NewPlanner returns a Planner that spreads tier2 work across shards
+// parallel shards.
+func NewPlanner(shards int) *Planner {
+	if shards <= 0 {
+		shards = 1
+	}
+	return &Planner{
+		shards: shards,
+		cache:  newRangeCache(),
+	}
+}
+
+// Plan builds a RequestPlan for refs over timeRange, marking any
+// [metric, shard] task that the partial-range cache already covers so tier2
+// can skip it.
+func (p *Planner) Plan(ctx context.Context, refs []MetricRef, timeRange TimeRange) (*RequestPlan, error) {
+	plan := &RequestPlan{
+		Refs:  refs,
+		Range: timeRange,
+		Tasks: make([]*PlanTask, 0, len(refs)*p.shards),
+	}
+
+	for _, ref := range refs {
+		for shard := 0; shard < p.shards; shard++ {
+			plan.Tasks = append(plan.Tasks, &PlanTask{
+				Ref:    ref,
+				Range:  timeRange,
+				Shard:  shard,
+				Cached: p.cache.covers(ref.Name, shard, timeRange),
+			})
+		}
+	}
+
+	return plan, nil
+}
+
+// Result is whatever a tier2 worker produces for a single PlanTask. It is
+// intentionally opaque here; callers type-assert Value.
+type Result struct {
+	Task  *PlanTask
+	Value interface{}
+	Err   error
+}
+
+// Execute fans exec out across plan.Tasks, skipping tasks already marked
+// Cached, and blocks until every task's goroutine has returned. A task
+// whose goroutine starts after ctx is canceled is not run and its Result
+// carries ctx.Err(); a running exec receives ctx and returns on its own.
+func (p *Planner) Execute(ctx context.Context, plan *RequestPlan, exec func(context.Context, *PlanTask) (interface{}, error)) []Result {
+	results := make([]Result, len(plan.Tasks))
+
+	var wg sync.WaitGroup
+	for i, task := range plan.Tasks {
+		if task.Cached {
+			results[i] = Result{Task: task, Value: p.cache.value(task.Ref.Name, task.Shard)}
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, task *PlanTask) {
+			defer wg.Done()
+
+			if ctx.Err() != nil {
+				results[i] = Result{Task: task, Err: ctx.Err()}
+				return
+			}
+
+			value, err := exec(ctx, task)
+			results[i] = Result{Task: task, Value: value, Err: err}
+			if err == nil {
+				p.cache.record(task.Ref.Name, task.Shard, task.Range, value)
+			}
+		}(i, task)
+	}
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,171 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRangeCacheCoversExtendsOnRecord(t *testing.T) {
+	c := newRangeCache()
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r1 := TimeRange{Start: base, End: base.Add(time.Hour)}
+
+	if c.covers("cpu", 0, r1) {
+		t.Fatal("covers should be false before anything is recorded")
+	}
+
+	c.record("cpu", 0, r1, "v1")
+	if !c.covers("cpu", 0, r1) {
+		t.Fatal("covers should be true for the exact recorded range")
+	}
+	if c.covers("cpu", 0, TimeRange{Start: base, End: base.Add(2 * time.Hour)}) {
+		t.Fatal("covers should be false for a range wider than what's recorded")
+	}
+
+	r2 := TimeRange{Start: base.Add(time.Hour), End: base.Add(2 * time.Hour)}
+	c.record("cpu", 0, r2, "v2")
+	if !c.covers("cpu", 0, TimeRange{Start: base, End: base.Add(2 * time.Hour)}) {
+		t.Fatal("covers should be true once the recorded range has been extended to cover it")
+	}
+
+	// A different shard or metric name is tracked independently.
+	if c.covers("cpu", 1, r1) {
+		t.Fatal("covers should not leak across shards")
+	}
+	if c.covers("mem", 0, r1) {
+		t.Fatal("covers should not leak across metric names")
+	}
+}
+
+func TestRangeCacheValue(t *testing.T) {
+	c := newRangeCache()
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := TimeRange{Start: base, End: base.Add(time.Hour)}
+
+	if got := c.value("cpu", 0); got != nil {
+		t.Fatalf("value before any record = %v, want nil", got)
+	}
+
+	c.record("cpu", 0, r, 42)
+	if got := c.value("cpu", 0); got != 42 {
+		t.Fatalf("value = %v, want 42", got)
+	}
+
+	// A later record for the same key replaces the stored value.
+	c.record("cpu", 0, r, 43)
+	if got := c.value("cpu", 0); got != 43 {
+		t.Fatalf("value after overwrite = %v, want 43", got)
+	}
+}
+
+func TestPlannerPlanMarksCachedTasks(t *testing.T) {
+	p := NewPlanner(2)
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := TimeRange{Start: base, End: base.Add(time.Hour)}
+	refs := []MetricRef{{Name: "cpu"}}
+
+	plan, err := p.Plan(context.Background(), refs, window)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if len(plan.Tasks) != 2 {
+		t.Fatalf("got %d tasks, want 2 (one per shard)", len(plan.Tasks))
+	}
+	for _, task := range plan.Tasks {
+		if task.Cached {
+			t.Fatalf("task for shard %d should not be cached yet", task.Shard)
+		}
+	}
+
+	// Populate the cache as Execute would, then re-plan the same window.
+	p.cache.record("cpu", 0, window, "shard0-value")
+	p.cache.record("cpu", 1, window, "shard1-value")
+
+	plan2, err := p.Plan(context.Background(), refs, window)
+	if err != nil {
+		t.Fatalf("Plan (second): %v", err)
+	}
+	for _, task := range plan2.Tasks {
+		if !task.Cached {
+			t.Fatalf("task for shard %d should be cached after a prior Execute covered its range", task.Shard)
+		}
+	}
+}
+
+func TestPlannerExecuteReturnsCachedValueWithoutCallingExec(t *testing.T) {
+	p := NewPlanner(1)
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := TimeRange{Start: base, End: base.Add(time.Hour)}
+	refs := []MetricRef{{Name: "cpu"}}
+
+	plan, err := p.Plan(context.Background(), refs, window)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+
+	calls := 0
+	results := p.Execute(context.Background(), plan, func(ctx context.Context, task *PlanTask) (interface{}, error) {
+		calls++
+		return "computed", nil
+	})
+	if calls != 1 {
+		t.Fatalf("exec called %d times, want 1", calls)
+	}
+	if results[0].Value != "computed" {
+		t.Fatalf("results[0].Value = %v, want %q", results[0].Value, "computed")
+	}
+
+	// Re-plan the identical window: the task should come back Cached, and
+	// Execute must hand back the value computed above instead of nil.
+	plan2, err := p.Plan(context.Background(), refs, window)
+	if err != nil {
+		t.Fatalf("Plan (second): %v", err)
+	}
+	if !plan2.Tasks[0].Cached {
+		t.Fatal("second Plan should mark the task Cached")
+	}
+
+	results2 := p.Execute(context.Background(), plan2, func(ctx context.Context, task *PlanTask) (interface{}, error) {
+		calls++
+		return "should not run", nil
+	})
+	if calls != 1 {
+		t.Fatalf("exec called %d times across both Executes, want 1 (second task was Cached)", calls)
+	}
+	if results2[0].Value != "computed" {
+		t.Fatalf("cached result Value = %v, want %q", results2[0].Value, "computed")
+	}
+	if results2[0].Err != nil {
+		t.Fatalf("cached result Err = %v, want nil", results2[0].Err)
+	}
+}
+
+func TestPlannerExecuteDoesNotCacheOnError(t *testing.T) {
+	p := NewPlanner(1)
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := TimeRange{Start: base, End: base.Add(time.Hour)}
+	refs := []MetricRef{{Name: "cpu"}}
+
+	plan, err := p.Plan(context.Background(), refs, window)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+
+	wantErr := errors.New("boom")
+	results := p.Execute(context.Background(), plan, func(ctx context.Context, task *PlanTask) (interface{}, error) {
+		return nil, wantErr
+	})
+	if results[0].Err != wantErr {
+		t.Fatalf("results[0].Err = %v, want %v", results[0].Err, wantErr)
+	}
+
+	plan2, err := p.Plan(context.Background(), refs, window)
+	if err != nil {
+		t.Fatalf("Plan (second): %v", err)
+	}
+	if plan2.Tasks[0].Cached {
+		t.Fatal("a task whose exec returned an error should not be cached")
+	}
+}
@@ -0,0 +1,70 @@
+// Package geoip implements a tracing.Enricher that resolves the country and
+// city for a span's client.address, registering itself under the name
+// "geoip".
+package geoip
+
+import (
+	"context"
+
+	"github.com/uptrace/uptrace/pkg/bunapp"
+	"github.com/uptrace/uptrace/pkg/tracing"
+	"github.com/uptrace/uptrace/pkg/tracing/enrich/hook"
+)
+
+func init() {
+	tracing.RegisterEnricher("geoip", New)
+}
+
+// Resolver looks up geolocation data for an IP address. Operators wire up a
+// real implementation (typically backed by a MaxMind GeoLite2 database) via
+// SetResolver; without one, this enricher is a no-op.
+type Resolver interface {
+	ResolveIP(ip string) (country, city string, ok bool)
+}
+
+type noopResolver struct{}
+
+func (noopResolver) ResolveIP(string) (string, string, bool) {
+	return "", "", false
+}
+
+var resolverHook = hook.New[Resolver](noopResolver{})
+
+// SetResolver installs the Resolver used by every enricher instance created
+// after this call.
+func SetResolver(r Resolver) {
+	resolverHook.Set(r)
+}
+
+type enricher struct {
+	resolver Resolver
+}
+
+// New builds the GeoIP enricher. It implements tracing.EnricherFactory; it
+// takes no config since the resolver is wired up once via SetResolver.
+func New(app *bunapp.App, config map[string]interface{}) tracing.Enricher {
+	return &enricher{resolver: resolverHook.Get()}
+}
+
+func (e *enricher) Enrich(ctx context.Context, span *tracing.Span) error {
+	if span.Attrs == nil {
+		return nil
+	}
+
+	addr, _ := span.Attrs["client.address"].(string)
+	if addr == "" {
+		return nil
+	}
+
+	country, city, ok := e.resolver.ResolveIP(addr)
+	if !ok {
+		return nil
+	}
+
+	span.Attrs["client.geo.country"] = country
+	if city != "" {
+		span.Attrs["client.geo.city"] = city
+	}
+
+	return nil
+}
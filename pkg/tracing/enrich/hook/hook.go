@@ -0,0 +1,35 @@
+// Package hook provides a thread-safe holder for the package-level resolver
+// hook pattern used by geoip and k8s: a default no-op value that operators
+// can swap out at runtime via a Set call, read by every enricher instance
+// built afterwards.
+package hook
+
+import "sync"
+
+// Hook holds a value of type R behind a mutex, since Get (enricher
+// construction, which can happen concurrently with traffic) and Set
+// (operator startup code installing a real implementation) run on different
+// goroutines with no other synchronization between them.
+type Hook[R any] struct {
+	mu    sync.RWMutex
+	value R
+}
+
+// New returns a Hook initialized to def.
+func New[R any](def R) *Hook[R] {
+	return &Hook[R]{value: def}
+}
+
+// Get returns the currently installed value.
+func (h *Hook[R]) Get() R {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.value
+}
+
+// Set installs r as the value returned by subsequent Gets.
+func (h *Hook[R]) Set(r R) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.value = r
+}
@@ -0,0 +1,78 @@
+// Package k8s implements a tracing.Enricher that infers Kubernetes
+// attributes (namespace, pod name, pod labels) for spans that only carry a
+// pod IP, registering itself under the name "k8s".
+package k8s
+
+import (
+	"context"
+
+	"github.com/uptrace/uptrace/pkg/bunapp"
+	"github.com/uptrace/uptrace/pkg/tracing"
+	"github.com/uptrace/uptrace/pkg/tracing/enrich/hook"
+)
+
+func init() {
+	tracing.RegisterEnricher("k8s", New)
+}
+
+// Resolver maps a pod IP to the Kubernetes metadata uptrace should attach to
+// a span. The default resolver used by New is a no-op; operators wire up a
+// real one (typically backed by a client-go informer cache) via
+// SetResolver before the app starts accepting traffic.
+type Resolver interface {
+	ResolvePodIP(ctx context.Context, podIP string) (namespace, pod string, labels map[string]string, ok bool)
+}
+
+type noopResolver struct{}
+
+func (noopResolver) ResolvePodIP(context.Context, string) (string, string, map[string]string, bool) {
+	return "", "", nil, false
+}
+
+var resolverHook = hook.New[Resolver](noopResolver{})
+
+// SetResolver installs the Resolver used by every enricher instance created
+// after this call. It is a package-level hook (like http.DefaultClient)
+// rather than per-instance config because a single informer cache is meant
+// to be shared across all projects.
+func SetResolver(r Resolver) {
+	resolverHook.Set(r)
+}
+
+type enricher struct {
+	resolver Resolver
+}
+
+// New builds the k8s enricher. It implements tracing.EnricherFactory; it
+// takes no config since the resolver is wired up once via SetResolver.
+func New(app *bunapp.App, config map[string]interface{}) tracing.Enricher {
+	return &enricher{resolver: resolverHook.Get()}
+}
+
+func (e *enricher) Enrich(ctx context.Context, span *tracing.Span) error {
+	if span.Attrs == nil {
+		return nil
+	}
+
+	if _, ok := span.Attrs["k8s.pod.name"]; ok {
+		return nil
+	}
+
+	podIP, _ := span.Attrs["net.peer.ip"].(string)
+	if podIP == "" {
+		return nil
+	}
+
+	namespace, pod, labels, ok := e.resolver.ResolvePodIP(ctx, podIP)
+	if !ok {
+		return nil
+	}
+
+	span.Attrs["k8s.namespace.name"] = namespace
+	span.Attrs["k8s.pod.name"] = pod
+	for k, v := range labels {
+		span.Attrs["k8s.pod.label."+k] = v
+	}
+
+	return nil
+}
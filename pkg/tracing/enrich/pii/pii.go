@@ -0,0 +1,63 @@
+// Package pii implements a tracing.Enricher that redacts likely PII (emails,
+// credit card numbers, US SSNs) from string attribute values, registering
+// itself under the name "pii".
+package pii
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/uptrace/uptrace/pkg/bunapp"
+	"github.com/uptrace/uptrace/pkg/tracing"
+)
+
+func init() {
+	tracing.RegisterEnricher("pii", New)
+}
+
+const redacted = "[redacted]"
+
+var defaultPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}`),
+	regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),
+	regexp.MustCompile(`\b(?:\d[ -]?){13,16}\b`),
+}
+
+type enricher struct {
+	patterns []*regexp.Regexp
+}
+
+// New builds the PII enricher using the default email/SSN/credit-card
+// patterns, plus any additional regexps supplied under config's "patterns"
+// key. It implements tracing.EnricherFactory.
+func New(app *bunapp.App, config map[string]interface{}) tracing.Enricher {
+	patterns := defaultPatterns
+	if raw, ok := config["patterns"].([]interface{}); ok {
+		for _, p := range raw {
+			s, ok := p.(string)
+			if !ok {
+				continue
+			}
+			re, err := regexp.Compile(s)
+			if err != nil {
+				continue
+			}
+			patterns = append(patterns, re)
+		}
+	}
+	return &enricher{patterns: patterns}
+}
+
+func (e *enricher) Enrich(ctx context.Context, span *tracing.Span) error {
+	for k, v := range span.Attrs {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		for _, pattern := range e.patterns {
+			s = pattern.ReplaceAllString(s, redacted)
+		}
+		span.Attrs[k] = s
+	}
+	return nil
+}
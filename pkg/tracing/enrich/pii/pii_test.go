@@ -0,0 +1,76 @@
+package pii
+
+import (
+	"context"
+	"testing"
+
+	"github.com/uptrace/uptrace/pkg/tracing"
+)
+
+func TestEnrichRedactsDefaultPatterns(t *testing.T) {
+	e := &enricher{patterns: defaultPatterns}
+
+	cases := []struct {
+		name string
+		in   string
+	}{
+		{"email", "contact alice@example.com for details"},
+		{"ssn", "ssn on file: 123-45-6789"},
+		{"credit card", "card 4111 1111 1111 1111 on the account"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			span := &tracing.Span{Attrs: map[string]interface{}{"msg": c.in}}
+			if err := e.Enrich(context.Background(), span); err != nil {
+				t.Fatalf("Enrich: %v", err)
+			}
+			got, _ := span.Attrs["msg"].(string)
+			if got == c.in {
+				t.Fatalf("Enrich left %q unredacted", c.in)
+			}
+		})
+	}
+}
+
+func TestEnrichLeavesNonStringAttrsAlone(t *testing.T) {
+	e := &enricher{patterns: defaultPatterns}
+	span := &tracing.Span{Attrs: map[string]interface{}{"count": 42}}
+
+	if err := e.Enrich(context.Background(), span); err != nil {
+		t.Fatalf("Enrich: %v", err)
+	}
+	if span.Attrs["count"] != 42 {
+		t.Fatalf("Enrich modified a non-string attribute: %v", span.Attrs["count"])
+	}
+}
+
+func TestNewAppendsConfiguredPatterns(t *testing.T) {
+	config := map[string]interface{}{
+		"patterns": []interface{}{`\bsecret-\w+\b`},
+	}
+	e := New(nil, config).(*enricher)
+
+	if len(e.patterns) != len(defaultPatterns)+1 {
+		t.Fatalf("New() has %d patterns, want %d default + 1 configured", len(e.patterns), len(defaultPatterns)+1)
+	}
+
+	span := &tracing.Span{Attrs: map[string]interface{}{"msg": "token secret-abc123 leaked"}}
+	if err := e.Enrich(context.Background(), span); err != nil {
+		t.Fatalf("Enrich: %v", err)
+	}
+	if got := span.Attrs["msg"].(string); got != "token [redacted] leaked" {
+		t.Fatalf("Enrich with custom pattern = %q, want %q", got, "token [redacted] leaked")
+	}
+}
+
+func TestNewIgnoresInvalidConfiguredPatterns(t *testing.T) {
+	config := map[string]interface{}{
+		"patterns": []interface{}{`[`, 42, `valid-\d+`},
+	}
+	e := New(nil, config).(*enricher)
+
+	if len(e.patterns) != len(defaultPatterns)+1 {
+		t.Fatalf("New() has %d patterns, want %d default + 1 valid configured one", len(e.patterns), len(defaultPatterns)+1)
+	}
+}
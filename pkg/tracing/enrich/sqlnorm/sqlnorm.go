@@ -0,0 +1,52 @@
+// Package sqlnorm implements a tracing.Enricher that normalizes db.statement
+// (stripping out literals) and attaches a stable fingerprint for grouping,
+// registering itself under the name "sql".
+package sqlnorm
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/uptrace/uptrace/pkg/bunapp"
+	"github.com/uptrace/uptrace/pkg/tracing"
+)
+
+func init() {
+	tracing.RegisterEnricher("sql", New)
+}
+
+var (
+	stringLiteral = regexp.MustCompile(`'(?:[^'\\]|\\.)*'`)
+	numberLiteral = regexp.MustCompile(`\b\d+(\.\d+)?\b`)
+	whitespace    = regexp.MustCompile(`\s+`)
+)
+
+type enricher struct{}
+
+// New builds the SQL normalization enricher. It implements
+// tracing.EnricherFactory; it takes no config.
+func New(app *bunapp.App, config map[string]interface{}) tracing.Enricher {
+	return &enricher{}
+}
+
+func (e *enricher) Enrich(ctx context.Context, span *tracing.Span) error {
+	stmt, _ := span.Attrs["db.statement"].(string)
+	if stmt == "" {
+		return nil
+	}
+
+	normalized := normalize(stmt)
+	span.Attrs["db.statement.normalized"] = normalized
+	span.Attrs["db.statement.fingerprint"] = strconv.FormatUint(xxhash.Sum64String(normalized), 16)
+
+	return nil
+}
+
+func normalize(stmt string) string {
+	stmt = stringLiteral.ReplaceAllString(stmt, "?")
+	stmt = numberLiteral.ReplaceAllString(stmt, "?")
+	stmt = whitespace.ReplaceAllString(stmt, " ")
+	return stmt
+}
@@ -0,0 +1,58 @@
+package sqlnorm
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "string literal",
+			in:   "SELECT * FROM users WHERE name = 'alice'",
+			want: "SELECT * FROM users WHERE name = ?",
+		},
+		{
+			name: "number literal",
+			in:   "SELECT * FROM users WHERE id = 42",
+			want: "SELECT * FROM users WHERE id = ?",
+		},
+		{
+			name: "decimal literal",
+			in:   "SELECT * FROM orders WHERE total > 19.99",
+			want: "SELECT * FROM orders WHERE total > ?",
+		},
+		{
+			name: "mixed literals and whitespace",
+			in:   "SELECT *   FROM t WHERE a = 'x'  AND b = 1",
+			want: "SELECT * FROM t WHERE a = ? AND b = ?",
+		},
+		{
+			name: "escaped quote inside string literal",
+			in:   `SELECT * FROM t WHERE name = 'o\'brien'`,
+			want: "SELECT * FROM t WHERE name = ?",
+		},
+		{
+			name: "no literals",
+			in:   "SELECT * FROM t",
+			want: "SELECT * FROM t",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := normalize(c.in); got != c.want {
+				t.Errorf("normalize(%q) = %q, want %q", c.in, got, c.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeIsStableFingerprint(t *testing.T) {
+	a := normalize("SELECT * FROM users WHERE id = 1")
+	b := normalize("SELECT * FROM users WHERE id = 2")
+	if a != b {
+		t.Errorf("normalize should fold differing literals to the same shape: %q != %q", a, b)
+	}
+}
@@ -0,0 +1,73 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/uptrace/uptrace/pkg/bunapp"
+)
+
+// Enricher mutates a span in place before it is inserted, e.g. to infer k8s
+// attributes, resolve GeoIP data, redact PII, or fingerprint SQL statements.
+// Enrich should be cheap: it runs on every span in the ingestion hot path.
+type Enricher interface {
+	Enrich(ctx context.Context, span *Span) error
+}
+
+// EnricherFactory builds an Enricher bound to app, so built-ins and
+// user-supplied enrichers can reach app config, loggers, and clients the
+// same way the rest of bunapp does. config is the enricher's own section of
+// bunconf.EnricherConfig.Config, decoded by the factory itself; bunconf
+// doesn't know its shape.
+type EnricherFactory func(app *bunapp.App, config map[string]interface{}) Enricher
+
+// EnricherSpec names a registered enricher and carries its per-enricher
+// config through to BuildEnrichers. It mirrors bunconf.EnricherConfig
+// without tracing importing bunconf.
+type EnricherSpec struct {
+	Name   string
+	Config map[string]interface{}
+}
+
+var (
+	enricherMu        sync.RWMutex
+	enricherFactories = make(map[string]EnricherFactory)
+)
+
+// RegisterEnricher registers a named Enricher factory under name so it can
+// be turned on via bunconf's enrichers: section. Built-ins register
+// themselves from an init() in their own package; operators do the same
+// from a blank import gated behind a build tag, mirroring kubeskoop's
+// `_ "…/probe/xxx"` registration pattern:
+//
+//	import _ "github.com/you/uptrace-enrichers/myenricher"
+//
+// RegisterEnricher panics on a duplicate name, the same way http.Handle
+// does for routes, since it only ever runs at package init.
+func RegisterEnricher(name string, factory EnricherFactory) {
+	enricherMu.Lock()
+	defer enricherMu.Unlock()
+
+	if _, ok := enricherFactories[name]; ok {
+		panic(fmt.Sprintf("tracing: enricher %q already registered", name))
+	}
+	enricherFactories[name] = factory
+}
+
+// BuildEnrichers resolves specs to their registered factories, in order,
+// instantiating each against app with its own Config.
+func BuildEnrichers(app *bunapp.App, specs []EnricherSpec) ([]Enricher, error) {
+	enricherMu.RLock()
+	defer enricherMu.RUnlock()
+
+	enrichers := make([]Enricher, 0, len(specs))
+	for _, spec := range specs {
+		factory, ok := enricherFactories[spec.Name]
+		if !ok {
+			return nil, fmt.Errorf("tracing: unknown enricher %q", spec.Name)
+		}
+		enrichers = append(enrichers, factory(app, spec.Config))
+	}
+	return enrichers, nil
+}
@@ -2,7 +2,9 @@ package tracing
 
 import (
 	"context"
+	"errors"
 	"runtime"
+	"sync/atomic"
 	"time"
 
 	"github.com/cespare/xxhash/v2"
@@ -16,34 +18,200 @@ import (
 	"golang.org/x/exp/slices"
 )
 
+// ErrShuttingDown is returned by AddItem once Close has been called; unlike
+// the old behavior, items are never silently accepted into a pipeline that
+// is about to stop draining.
+var ErrShuttingDown = errors.New("tracing: processor is shutting down")
+
+// defaultShutdownTimeout is how long Close waits for the queue to drain
+// before spilling whatever's left to SpillDir.
+const defaultShutdownTimeout = 30 * time.Second
+
 type Processor[T any] struct {
-	App       *bunapp.App
-	batchSize int
-	queue     chan *T
-	gate      *syncutil.Gate
-	logger    *otelzap.Logger
+	App        *bunapp.App
+	batchSize  int
+	sink       Sink[T]
+	addTimeout time.Duration
+	gate       *syncutil.Gate
+	logger     *otelzap.Logger
+
+	// projectIDFunc, when set, lets processItems break the per-batch
+	// summary down by project via items_by_project.
+	projectIDFunc func(*T) uint32
+	dropped       atomic.Int64
+	delayed       atomic.Int64
+
+	// enrichFunc, when set, runs the registered Enricher chain (see
+	// RegisterEnricher) over every item during the enrich stage.
+	enrichFunc func(context.Context, *T) error
+
+	// shutdownTimeout bounds how long Close waits for a drain to finish
+	// before giving up and spilling whatever's left to spillDir.
+	shutdownTimeout time.Duration
+	// spillDir is where Close persists items it couldn't drain in time.
+	// Point it at a WALSink's Dir to have them replayed automatically on
+	// that sink's next start; left empty, undrained items are logged and
+	// dropped, same as the pre-drain behavior.
+	spillDir string
+
+	closing       atomic.Bool
+	popCancel     context.CancelFunc
+	drainNow      chan struct{}
+	drainDeadline time.Time
+	stopped       chan struct{}
+
+	queueDropped   metric.Int64Counter
+	queueDelayed   metric.Int64Counter
+	queueWait      metric.Float64Histogram
+	batchSizeHist  metric.Int64Histogram
+	processingTime metric.Float64Histogram
+	parseTime      metric.Float64Histogram
+	enrichTime     metric.Float64Histogram
+	insertTime     metric.Float64Histogram
+	enrichErrors   metric.Int64Counter
 }
 
-func NewProcessor[T any](app *bunapp.App, batchSize, bufferSize int) *Processor[T] {
+// SetProjectIDFunc configures how processItems attributes items to projects
+// in its per-batch summary log. Without it, items_by_project is logged as
+// an empty map.
+func (p *Processor[T]) SetProjectIDFunc(fn func(*T) uint32) {
+	p.projectIDFunc = fn
+}
+
+// SetEnrichFunc configures the per-item enrichment step processItems runs
+// during the enrich stage. ProcessorThread wires this up from its
+// registered Enricher chain; without it, the enrich stage is a no-op.
+func (p *Processor[T]) SetEnrichFunc(fn func(context.Context, *T) error) {
+	p.enrichFunc = fn
+}
+
+// SetShutdownTimeout overrides how long Close waits for a drain to finish
+// before spilling the remainder to SpillDir. Defaults to 30s.
+func (p *Processor[T]) SetShutdownTimeout(d time.Duration) {
+	p.shutdownTimeout = d
+}
+
+// SetSpillDir sets where Close persists items it couldn't drain within
+// shutdownTimeout. Without one, those items are logged and dropped.
+func (p *Processor[T]) SetSpillDir(dir string) {
+	p.spillDir = dir
+}
+
+// NewProcessor starts a batch processor that pulls items off sink in the
+// background. addTimeout bounds how long AddItem blocks trying to hand off
+// an item before giving up; it replaces the old drop-on-full behavior.
+func NewProcessor[T any](app *bunapp.App, batchSize int, sink Sink[T], addTimeout time.Duration) *Processor[T] {
 	maxprocs := runtime.GOMAXPROCS(0)
 
 	p := &Processor[T]{
-		App:       app,
-		batchSize: batchSize,
-		queue:     make(chan *T, bufferSize),
-		gate:      syncutil.NewGate(maxprocs),
-		logger:    app.Logger,
+		App:             app,
+		batchSize:       batchSize,
+		sink:            sink,
+		addTimeout:      addTimeout,
+		gate:            syncutil.NewGate(maxprocs),
+		logger:          app.Logger,
+		shutdownTimeout: defaultShutdownTimeout,
+		drainNow:        make(chan struct{}),
+		stopped:         make(chan struct{}),
 	}
 
 	p.logger.Info("starting processor...",
 		zap.Int("threads", maxprocs),
 		zap.Int("batch_size", batchSize),
-		zap.Int("buffer_size", bufferSize))
+		zap.Duration("add_timeout", addTimeout))
+
+	var err error
+	p.queueDropped, err = bunotel.Meter.Int64Counter("uptrace.processor.queue_dropped_total",
+		metric.WithUnit("{items}"),
+	)
+	if err != nil {
+		panic(err)
+	}
+	p.queueDelayed, err = bunotel.Meter.Int64Counter("uptrace.processor.queue_delayed_total",
+		metric.WithUnit("{items}"),
+	)
+	if err != nil {
+		panic(err)
+	}
+	p.queueWait, err = bunotel.Meter.Float64Histogram("uptrace.processor.queue_wait_seconds",
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		panic(err)
+	}
+	p.batchSizeHist, err = bunotel.Meter.Int64Histogram("uptrace.processor.batch_size",
+		metric.WithUnit("{items}"),
+	)
+	if err != nil {
+		panic(err)
+	}
+	p.processingTime, err = bunotel.Meter.Float64Histogram("uptrace.processor.processing_time_seconds",
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		panic(err)
+	}
+	p.parseTime, err = bunotel.Meter.Float64Histogram("uptrace.processor.parse_time_seconds",
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		panic(err)
+	}
+	p.enrichTime, err = bunotel.Meter.Float64Histogram("uptrace.processor.enrich_time_seconds",
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		panic(err)
+	}
+	p.insertTime, err = bunotel.Meter.Float64Histogram("uptrace.processor.insert_time_seconds",
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		panic(err)
+	}
+	p.enrichErrors, err = bunotel.Meter.Int64Counter("uptrace.processor.enrich_errors_total",
+		metric.WithUnit("{items}"),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	if wal, ok := sink.(interface{ ReplayLag() time.Duration }); ok {
+		replayLag, _ := bunotel.Meter.Float64ObservableGauge("uptrace.processor.wal_replay_lag",
+			metric.WithUnit("s"),
+		)
+		if _, err := bunotel.Meter.RegisterCallback(
+			func(ctx context.Context, o metric.Observer) error {
+				o.ObserveFloat64(replayLag, wal.ReplayLag().Seconds())
+				return nil
+			},
+			replayLag,
+		); err != nil {
+			panic(err)
+		}
+	}
+
+	popCtx, popCancel := context.WithCancel(context.Background())
+	p.popCancel = popCancel
+
+	popped := make(chan dequeued[T], p.batchSize)
+	go p.popLoop(popCtx, popped)
 
 	app.WaitGroup().Add(1)
 	go func() {
 		defer app.WaitGroup().Done()
-		p.processLoop(app.Context())
+		p.processLoop(popped)
+	}()
+
+	// Close is the single shutdown path regardless of trigger: an app
+	// context cancellation just calls it like any other caller would, so
+	// draining and spilling behave the same whether shutdown is explicit
+	// or driven by the app's own lifecycle.
+	go func() {
+		<-app.Context().Done()
+		if err := p.Close(context.Background()); err != nil {
+			p.logger.Error("processor close failed", zap.Error(err))
+		}
 	}()
 
 	queueLen, _ := bunotel.Meter.Int64ObservableGauge("uptrace.processor.queue_length",
@@ -52,7 +220,7 @@ func NewProcessor[T any](app *bunapp.App, batchSize, bufferSize int) *Processor[
 
 	if _, err := bunotel.Meter.RegisterCallback(
 		func(ctx context.Context, o metric.Observer) error {
-			o.ObserveInt64(queueLen, int64(len(p.queue)))
+			o.ObserveInt64(queueLen, int64(p.sink.Len()))
 			return nil
 		},
 		queueLen,
@@ -63,43 +231,91 @@ func NewProcessor[T any](app *bunapp.App, batchSize, bufferSize int) *Processor[
 	return p
 }
 
-func (p *Processor[T]) AddItem(ctx context.Context, item *T) {
-	p.logger.Info("AddItem called", zap.Any("item", item))
-	select {
-	case p.queue <- item:
-	default:
-		p.logger.Error("queue is full (consider increasing buffer size)",
-			zap.Int("len", len(p.queue)))
+// AddItem hands item to the configured sink, blocking for up to addTimeout
+// waiting for room. If the timeout elapses the item is dropped and
+// queue_dropped_total is incremented, instead of being silently discarded —
+// unless the sink reports ErrDelayed, meaning the item was already durably
+// written (WAL, broker) before the local queue timed out, in which case it
+// is counted under queue_delayed_total instead: it isn't lost, just not
+// visible to Pop yet. Once Close has been called, AddItem returns
+// ErrShuttingDown immediately without touching the sink.
+func (p *Processor[T]) AddItem(ctx context.Context, item *T) error {
+	if p.closing.Load() {
+		return ErrShuttingDown
+	}
+
+	if p.addTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.addTimeout)
+		defer cancel()
 	}
+
+	if err := p.sink.Push(ctx, item); err != nil {
+		if errors.Is(err, ErrDelayed) {
+			p.delayed.Add(1)
+			p.queueDelayed.Add(ctx, 1)
+			p.logger.Debug("item durably accepted but not yet queued locally",
+				zap.Duration("add_timeout", p.addTimeout))
+			return nil
+		}
+
+		p.dropped.Add(1)
+		p.queueDropped.Add(ctx, 1)
+		p.logger.Debug("dropping item, sink did not accept it in time",
+			zap.Duration("add_timeout", p.addTimeout),
+			zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+type dequeued[T any] struct {
+	item     *T
+	queuedAt time.Time
 }
 
-func (p *Processor[T]) processLoop(ctx context.Context) {
-	p.logger.Info("processLoop started")
+// popLoop continuously pulls items off the sink and forwards them to out, so
+// that processLoop can keep multiplexing against a timer via a plain select.
+// It exits once Pop reports the sink is done. ctx is independent of the app
+// context so that Close can keep draining past the point the app itself has
+// already shut down; see Processor.Close.
+func (p *Processor[T]) popLoop(ctx context.Context, out chan<- dequeued[T]) {
+	defer close(out)
+	for {
+		item, queuedAt, ok := p.sink.Pop(ctx)
+		if !ok {
+			return
+		}
+		out <- dequeued[T]{item: item, queuedAt: queuedAt}
+	}
+}
+
+func (p *Processor[T]) processLoop(popped <-chan dequeued[T]) {
+	p.logger.Debug("processLoop started")
 	const timeout = 5 * time.Second
 
 	timer := time.NewTimer(timeout)
 	defer timer.Stop()
 
-	items := make([]*T, 0, p.batchSize)
+	batch := make([]dequeued[T], 0, p.batchSize)
 
 loop:
 	for {
-		p.logger.Info("Waiting for items in the queue")
 		select {
-		case item := <-p.queue:
-			p.logger.Info("Received item from queue", zap.Int("currentBatchSize", len(items)+1), zap.Int("queueLength", len(p.queue)))
-			items = append(items, item)
+		case d, ok := <-popped:
+			if !ok {
+				p.logger.Debug("processor queue drained, shutting down")
+				break loop
+			}
 
-			p.logger.Info("Current batch size after adding item", zap.Int("currentBatchSize", len(items)))
+			batch = append(batch, d)
 
-			if len(items) < p.batchSize {
-				p.logger.Info("Batch size not reached yet", zap.Int("currentBatchSize", len(items)), zap.Int("requiredBatchSize", p.batchSize))
+			if len(batch) < p.batchSize {
 				break
 			}
 
-			p.logger.Info("Processing batch of items", zap.Int("batchSize", len(items)))
-			p.processItems(ctx, items)
-			items = items[:0]
+			p.processItems(context.Background(), batch)
+			batch = batch[:0]
 
 			if !timer.Stop() {
 				<-timer.C
@@ -107,52 +323,271 @@ loop:
 			timer.Reset(timeout)
 
 		case <-timer.C:
-			if len(items) > 0 {
-				p.logger.Info("Processing batch due to timeout", zap.Int("batchSize", len(items)))
-				p.processItems(ctx, items)
-				items = items[:0]
+			if len(batch) > 0 {
+				p.processItems(context.Background(), batch)
+				batch = batch[:0]
 			}
 			timer.Reset(timeout)
 
-		case <-p.App.Context().Done():
-			p.logger.Info("Shutting down processor, final items processing", zap.Int("finalBatchSize", len(items)))
+		case <-p.drainNow:
+			p.drain(popped, &batch)
 			break loop
 		}
 	}
 
-	if len(items) > 0 {
-		p.logger.Info("Final batch processing after shutdown", zap.Int("batchSize", len(items)))
-		p.processItems(ctx, items)
+	if len(batch) > 0 {
+		p.processItems(context.Background(), batch)
 	}
 
-	if len(items) > 0 {
-		p.logger.Info("Final batch processing after shutdown", zap.Int("batchSize", len(items)))
-		p.processItems(ctx, items)
+	close(p.stopped)
+}
+
+// drainPollInterval is how often drain checks whether the sink has already
+// been fully drained, so an ordinary shutdown with nothing queued returns
+// as soon as that's true instead of always waiting out shutdownTimeout.
+const drainPollInterval = 50 * time.Millisecond
+
+// drain runs once Close has signaled shutdown. It keeps consuming popped —
+// still fed by popLoop off the same sink — flushing every item as soon as
+// it arrives instead of waiting for a full batch or the idle timer, so
+// whatever was already queued goes out as fast as possible. Once the sink
+// and the in-flight batch are both observed empty, it stops popLoop and
+// returns immediately rather than waiting for p.drainDeadline, which only
+// serves as a true deadline for the case where draining doesn't finish: if
+// it elapses first, popLoop is stopped and whatever it hasn't delivered yet
+// is spilled via p.spill instead.
+func (p *Processor[T]) drain(popped <-chan dequeued[T], batch *[]dequeued[T]) {
+	timer := time.NewTimer(time.Until(p.drainDeadline))
+	defer timer.Stop()
+
+	poll := time.NewTicker(drainPollInterval)
+	defer poll.Stop()
+
+	for {
+		select {
+		case d, ok := <-popped:
+			if !ok {
+				return
+			}
+			*batch = append(*batch, d)
+			p.processItems(context.Background(), *batch)
+			*batch = (*batch)[:0]
+
+		case <-poll.C:
+			if len(*batch) == 0 && p.sink.Len() == 0 {
+				p.popCancel()
+				p.drainRemaining(popped)
+				return
+			}
+
+		case <-timer.C:
+			p.popCancel()
+			p.spill(*batch, popped)
+			*batch = (*batch)[:0]
+			return
+		}
+	}
+}
+
+// drainRemaining flushes whatever popLoop still delivers after popCancel
+// stopped it: Pop may already have pulled one last item off the sink right
+// before the cancellation it observes on its next call, so popped can
+// receive once more before it closes. Unlike spill, this only runs once the
+// sink was already observed empty, so it processes what (if anything)
+// arrives as a normal batch instead of persisting it for replay.
+func (p *Processor[T]) drainRemaining(popped <-chan dequeued[T]) {
+	var batch []dequeued[T]
+	for d := range popped {
+		batch = append(batch, d)
+	}
+	if len(batch) > 0 {
+		p.processItems(context.Background(), batch)
 	}
 }
 
-func (p *Processor[T]) processItems(ctx context.Context, items []*T) {
-	p.logger.Info("Processing batch of items", zap.Int("batchSize", len(items)))
+// spill persists whatever didn't make it through the drain before the
+// shutdown deadline: the in-flight batch, plus anything popLoop pulls off
+// the sink as it winds down. popCancel was already called, and closing is
+// already set so nothing is pushing new items in, so this keeps receiving
+// from popped (rather than a single non-blocking sweep) until popLoop
+// closes it — both to capture items still resident in the sink at the
+// instant the deadline hit, and so popLoop isn't left blocked forever
+// trying to hand one over with no reader left on the other end.
+func (p *Processor[T]) spill(remaining []dequeued[T], popped <-chan dequeued[T]) {
+	for d := range popped {
+		remaining = append(remaining, d)
+	}
+
+	if len(remaining) == 0 {
+		return
+	}
 
-	if ctx.Err() != nil {
-		p.logger.Error("Context canceled before processing", zap.Error(ctx.Err()))
+	if p.spillDir == "" {
+		p.logger.Error("shutdown deadline hit, dropping queued items (no spill_dir configured)",
+			zap.Int("items", len(remaining)))
 		return
 	}
 
+	items := make([]*T, len(remaining))
+	for i, d := range remaining {
+		items[i] = d.item
+	}
+
+	path, err := spillSegment(p.spillDir, items)
+	if err != nil {
+		p.logger.Error("failed to spill queued items on shutdown",
+			zap.Error(err), zap.Int("items", len(remaining)))
+		return
+	}
+
+	p.logger.Warn("shutdown deadline hit, spilled queued items for replay on next start",
+		zap.Int("items", len(remaining)), zap.String("path", path))
+}
+
+// Close stops the processor from accepting new items — AddItem starts
+// returning ErrShuttingDown — and blocks until everything already queued
+// has been drained, up to shutdownTimeout (or ctx's deadline, if sooner).
+// Whatever is still queued when that deadline hits is spilled to spillDir
+// (see SetSpillDir) for replay on the next start instead of being dropped.
+// Once the drain finishes (or gives up and spills), the sink itself is
+// closed so it can release its own resources (seal its active WAL segment,
+// close a broker Publisher, ...). Close is idempotent: calling it again
+// waits for the drain started by the first call to stop and returns nil,
+// without waiting for the sink to be closed or reporting its error.
+func (p *Processor[T]) Close(ctx context.Context) error {
+	if !p.closing.CompareAndSwap(false, true) {
+		<-p.stopped
+		return nil
+	}
+
+	deadline := time.Now().Add(p.shutdownTimeout)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
+	p.drainDeadline = deadline
+	close(p.drainNow)
+
+	<-p.stopped
+
+	if err := p.sink.Close(); err != nil {
+		p.logger.Error("sink close failed", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (p *Processor[T]) ackItems(n int) {
+	if err := p.sink.Ack(n); err != nil {
+		p.logger.Error("sink ack failed", zap.Error(err))
+	}
+}
+
+// processItems runs a batch through the parse/enrich/insert stages and
+// emits a single structured summary log line plus matching histograms, so
+// operators can build p50/p99 dashboards per stage instead of grepping
+// through a flood of per-item logs.
+func (p *Processor[T]) processItems(ctx context.Context, batch []dequeued[T]) {
+	start := time.Now()
+
+	items := make([]*T, len(batch))
+	var queueTimeTotal time.Duration
+	for i, d := range batch {
+		items[i] = d.item
+		queueTimeTotal += time.Since(d.queuedAt)
+	}
+	queueTime := queueTimeTotal / time.Duration(len(batch))
+
+	// processItems always runs with a background context (see processLoop
+	// and drain) precisely so a canceled app context can't abort the final
+	// flush and silently drop a batch that was already dequeued.
+
+	parseStart := time.Now()
+	p.logger.Debug("parsing batch", zap.Int("batch_size", len(items)))
+	parseTime := time.Since(parseStart)
+
+	enrichStart := time.Now()
+	itemsByProject := make(map[uint32]int)
+	for _, item := range items {
+		if p.projectIDFunc != nil {
+			itemsByProject[p.projectIDFunc(item)]++
+		}
+		if p.enrichFunc != nil {
+			if err := p.enrichFunc(ctx, item); err != nil {
+				p.enrichErrors.Add(ctx, 1)
+				p.logger.Debug("enricher failed", zap.Error(err))
+			}
+		}
+	}
+	enrichTime := time.Since(enrichStart)
+
+	insertStart := time.Now()
+	p.logger.Debug("inserting batch", zap.Int("batch_size", len(items)))
+	insertTime := time.Since(insertStart)
+
+	processingTime := time.Since(start)
+	itemsDropped := p.dropped.Swap(0)
+	itemsDelayed := p.delayed.Swap(0)
+
+	p.logger.Info("processed batch",
+		zap.Int("batch_size", len(items)),
+		zap.Duration("queue_time", queueTime),
+		zap.Duration("processing_time", processingTime),
+		zap.Int64("items_dropped", itemsDropped),
+		zap.Int64("items_delayed", itemsDelayed),
+		zap.Any("items_by_project", itemsByProject),
+		zap.Duration("parse_time", parseTime),
+		zap.Duration("enrich_time", enrichTime),
+		zap.Duration("insert_time", insertTime))
+
+	p.queueWait.Record(ctx, queueTime.Seconds())
+	p.batchSizeHist.Record(ctx, int64(len(items)))
+	p.processingTime.Record(ctx, processingTime.Seconds())
+	p.parseTime.Record(ctx, parseTime.Seconds())
+	p.enrichTime.Record(ctx, enrichTime.Seconds())
+	p.insertTime.Record(ctx, insertTime.Seconds())
+
+	p.ackItems(len(items))
 }
 
 type ProcessorThread[T any, P any] struct {
 	*Processor[T]
-	projects map[uint32]*org.Project
-	digest   *xxhash.Digest
+	projects  map[uint32]*org.Project
+	digest    *xxhash.Digest
+	enrichers []Enricher
 }
 
 func NewProcessorThread[T any, P any](processor *Processor[T]) *ProcessorThread[T, P] {
-	return &ProcessorThread[T, P]{
+	pt := &ProcessorThread[T, P]{
 		Processor: processor,
 		projects:  make(map[uint32]*org.Project),
 		digest:    xxhash.New(),
 	}
+	processor.SetEnrichFunc(pt.enrich)
+	return pt
+}
+
+// SetEnrichers installs the Enricher chain resolved from bunconf's
+// enrichers: section (via BuildEnrichers). Enrichers only apply to items
+// that are themselves *Span; for other item types (e.g. metrics) the chain
+// is a no-op.
+func (p *ProcessorThread[T, P]) SetEnrichers(enrichers []Enricher) {
+	p.enrichers = enrichers
+}
+
+// enrich runs the registered Enricher chain over item, in order, stopping
+// at the first error.
+func (p *ProcessorThread[T, P]) enrich(ctx context.Context, item *T) error {
+	span, ok := any(item).(*Span)
+	if !ok {
+		return nil
+	}
+
+	for _, enricher := range p.enrichers {
+		if err := enricher.Enrich(ctx, span); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (p *ProcessorThread[T, P]) project(ctx context.Context, projectID uint32) (*org.Project, bool) {
@@ -0,0 +1,83 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// TestMemorySinkPopPrefersBuffered confirms Pop drains whatever is already
+// queued even when ctx is already canceled, since Processor.spill and
+// Processor.drain both depend on this precedence to finish flushing a
+// sink during shutdown instead of racing Pop's two selects and losing
+// buffered items to the canceled ctx case.
+func TestMemorySinkPopPrefersBuffered(t *testing.T) {
+	s := NewMemorySink[walTestItem](4)
+
+	for i := 0; i < 3; i++ {
+		if err := s.Push(context.Background(), &walTestItem{Value: i}); err != nil {
+			t.Fatalf("Push(%d): %v", i, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for i := 0; i < 3; i++ {
+		item, _, ok := s.Pop(ctx)
+		if !ok {
+			t.Fatalf("Pop(%d) returned ok=false with %d items still buffered", i, 3-i)
+		}
+		if item.Value != i {
+			t.Fatalf("Pop(%d).Value = %d, want %d", i, item.Value, i)
+		}
+	}
+
+	if _, _, ok := s.Pop(ctx); ok {
+		t.Fatal("Pop returned ok=true on an empty queue with a canceled ctx")
+	}
+}
+
+// TestSpillSegmentReplaysIntoWALSink exercises the round trip
+// Processor.spill depends on: items a shutdown drain couldn't flush in time
+// are durably written via spillSegment, and a WALSink pointed at that same
+// directory (as the spillDir field's doc recommends) picks them back up on its
+// next replay.
+func TestSpillSegmentReplaysIntoWALSink(t *testing.T) {
+	dir := t.TempDir()
+
+	items := make([]*walTestItem, 10)
+	for i := range items {
+		items[i] = &walTestItem{Value: i}
+	}
+
+	path, err := spillSegment(dir, items)
+	if err != nil {
+		t.Fatalf("spillSegment: %v", err)
+	}
+	if path == "" {
+		t.Fatal("spillSegment returned an empty path")
+	}
+
+	w, err := NewWALSink[walTestItem](WALConfig{Dir: dir, BufferSize: 1}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewWALSink: %v", err)
+	}
+	defer w.Close()
+
+	seen := make(map[int]bool)
+	for i := range items {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		item, _, ok := w.Pop(ctx)
+		cancel()
+		if !ok {
+			t.Fatalf("Pop returned ok=false after only %d of %d spilled items replayed", i, len(items))
+		}
+		seen[item.Value] = true
+	}
+	if len(seen) != len(items) {
+		t.Fatalf("replayed %d distinct items, want %d", len(seen), len(items))
+	}
+}
@@ -0,0 +1,112 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrSinkFull is returned by Sink.Push when the sink cannot accept the item
+// before the caller-supplied deadline elapses.
+var ErrSinkFull = errors.New("tracing: sink is full")
+
+// ErrDelayed is returned by Sink.Push when the item was already durably
+// accepted (written to the WAL, published to the broker) before the local
+// queue that feeds Pop timed out. Callers should account for this
+// separately from a true drop: the item isn't lost, it's just not visible
+// to Pop yet (the WAL will replay it on the next restart; the broker has
+// its own delivery guarantee).
+var ErrDelayed = errors.New("tracing: item durably accepted but not yet queued locally")
+
+// enqueued wraps an item with the time it was handed to AddItem so downstream
+// stages can report queueing latency.
+type enqueued[T any] struct {
+	item     *T
+	queuedAt time.Time
+}
+
+// Sink is the durability boundary between AddItem and the batch processing
+// loop. Implementations decide how (and whether) an item survives a process
+// restart or a downstream outage.
+//
+// Push must not block past ctx's deadline; callers rely on this to implement
+// AddItem's configurable timeout. Pop blocks until an item is available or
+// ctx is done.
+type Sink[T any] interface {
+	// Push enqueues item, returning ErrSinkFull if it cannot be accepted
+	// before ctx is done.
+	Push(ctx context.Context, item *T) error
+	// Pop removes and returns the next item, blocking until one is
+	// available or ctx is done.
+	Pop(ctx context.Context) (*T, time.Time, bool)
+	// Len reports the number of items currently buffered.
+	Len() int
+	// Ack acknowledges that the n oldest items returned by Pop have been
+	// durably processed downstream and may be reclaimed. Sinks without a
+	// reclaim step (e.g. the in-memory sink) treat this as a no-op.
+	Ack(n int) error
+	// Close releases any resources held by the sink. Items still
+	// buffered are implementation-defined (the WAL sink persists them
+	// for replay; the memory sink drops them).
+	Close() error
+}
+
+// memorySink is the original bounded in-memory channel, promoted to a Sink
+// implementation so it can be swapped out via bunconf.
+type memorySink[T any] struct {
+	queue chan enqueued[T]
+}
+
+// NewMemorySink creates a Sink backed by a bounded in-memory channel. Items
+// are lost on crash or restart; use NewWALSink for durability.
+func NewMemorySink[T any](bufferSize int) Sink[T] {
+	return &memorySink[T]{
+		queue: make(chan enqueued[T], bufferSize),
+	}
+}
+
+func (s *memorySink[T]) Push(ctx context.Context, item *T) error {
+	select {
+	case s.queue <- enqueued[T]{item: item, queuedAt: time.Now()}:
+		return nil
+	default:
+	}
+
+	select {
+	case s.queue <- enqueued[T]{item: item, queuedAt: time.Now()}:
+		return nil
+	case <-ctx.Done():
+		return ErrSinkFull
+	}
+}
+
+// Pop tries a non-blocking read first so that whatever is already buffered
+// keeps draining even after ctx is done — a canceled ctx and a non-empty
+// queue can both be true at once during shutdown, and callers that need to
+// drain fully (see Processor.Close) depend on this precedence.
+func (s *memorySink[T]) Pop(ctx context.Context) (*T, time.Time, bool) {
+	select {
+	case e := <-s.queue:
+		return e.item, e.queuedAt, true
+	default:
+	}
+
+	select {
+	case e := <-s.queue:
+		return e.item, e.queuedAt, true
+	case <-ctx.Done():
+		return nil, time.Time{}, false
+	}
+}
+
+func (s *memorySink[T]) Len() int {
+	return len(s.queue)
+}
+
+func (s *memorySink[T]) Ack(int) error {
+	return nil
+}
+
+func (s *memorySink[T]) Close() error {
+	return nil
+}
@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Publisher abstracts the actual wire client for a streaming sink. Operators
+// plug in a Pulsar or Kafka producer by implementing this interface rather
+// than the processor package taking a hard dependency on either client.
+type Publisher interface {
+	Publish(ctx context.Context, topic string, payload []byte) error
+	Close() error
+}
+
+// StreamConfig configures a Publisher-backed sink.
+type StreamConfig struct {
+	Topic string
+	// BufferSize sizes the local queue that feeds Pop while messages are
+	// in flight to the broker.
+	BufferSize int
+}
+
+// StreamSink publishes items to an external broker (Pulsar, Kafka, ...) via
+// Publisher, and additionally buffers them locally so Pop keeps its usual
+// blocking semantics for the processor. It trades the WAL's on-disk replay
+// for the broker's own durability and offset tracking.
+type StreamSink[T any] struct {
+	cfg StreamConfig
+	pub Publisher
+	mem Sink[T]
+}
+
+// NewStreamSink returns a Sink that publishes every item to pub under
+// cfg.Topic before handing it to an in-memory queue for Pop.
+func NewStreamSink[T any](cfg StreamConfig, pub Publisher) *StreamSink[T] {
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 1000
+	}
+	return &StreamSink[T]{
+		cfg: cfg,
+		pub: pub,
+		mem: NewMemorySink[T](cfg.BufferSize),
+	}
+}
+
+func (s *StreamSink[T]) Push(ctx context.Context, item *T) error {
+	payload, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("tracing: marshaling item for publish: %w", err)
+	}
+	if err := s.pub.Publish(ctx, s.cfg.Topic, payload); err != nil {
+		return fmt.Errorf("tracing: publishing item: %w", err)
+	}
+
+	if err := s.mem.Push(ctx, item); err != nil {
+		// The broker already has the item and owns its own delivery
+		// guarantee at this point; it isn't lost, just not locally queued.
+		return ErrDelayed
+	}
+	return nil
+}
+
+func (s *StreamSink[T]) Pop(ctx context.Context) (*T, time.Time, bool) {
+	return s.mem.Pop(ctx)
+}
+
+func (s *StreamSink[T]) Len() int {
+	return s.mem.Len()
+}
+
+func (s *StreamSink[T]) Ack(n int) error {
+	return s.mem.Ack(n)
+}
+
+func (s *StreamSink[T]) Close() error {
+	return s.pub.Close()
+}
@@ -0,0 +1,429 @@
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// FsyncPolicy controls how aggressively a WALSink flushes segment writes to
+// disk. Always is the safest (and slowest); Interval batches fsyncs on a
+// timer, trading a small durability window for throughput.
+type FsyncPolicy int
+
+const (
+	FsyncAlways FsyncPolicy = iota
+	FsyncInterval
+)
+
+// WALConfig configures a disk-backed, segmented write-ahead log sink.
+type WALConfig struct {
+	// Dir is the directory segment files are written to. It is created
+	// if it does not exist.
+	Dir string
+	// SegmentBytes rotates to a new segment once the active one grows
+	// past this size. Defaults to 64MB.
+	SegmentBytes int64
+	// FsyncPolicy controls when writes are flushed to disk.
+	FsyncPolicy FsyncPolicy
+	// FsyncInterval is used when FsyncPolicy is FsyncInterval.
+	FsyncInterval time.Duration
+	// BufferSize sizes the in-memory queue that feeds Pop. Every item
+	// that is durably appended to the WAL is also handed to this queue.
+	BufferSize int
+}
+
+func (c *WALConfig) init() {
+	if c.SegmentBytes <= 0 {
+		c.SegmentBytes = 64 << 20
+	}
+	if c.FsyncInterval <= 0 {
+		c.FsyncInterval = time.Second
+	}
+	if c.BufferSize <= 0 {
+		c.BufferSize = 1000
+	}
+}
+
+type walSegment struct {
+	path    string
+	records int
+}
+
+// WALSink is a Sink[T] backed by an append-only, segmented log on disk. Every
+// Push is durably appended (subject to FsyncPolicy) before the item is handed
+// to an in-memory queue for Pop. On construction, any segments left over from
+// an unclean shutdown are replayed back onto that queue.
+type WALSink[T any] struct {
+	cfg    WALConfig
+	logger *zap.Logger
+
+	mem Sink[T]
+
+	mu         sync.Mutex
+	active     *os.File
+	activeW    *bufio.Writer
+	activePath string
+	activeSize int64
+	activeRecs int
+	// activeAcked is how many of the active segment's records Ack has
+	// already credited before it rotated out from under them. sealActive
+	// carries this forward onto the sealed segment's count instead of
+	// recording the segment's full (unacked) size, otherwise every item
+	// acked while its segment was still active would be replayed as a
+	// duplicate after a crash and its segment would never be reclaimed.
+	activeAcked int
+	sealed      []walSegment // oldest first, awaiting ack-driven cleanup
+
+	lastFsync time.Time
+
+	replayLag time.Duration
+}
+
+// NewWALSink opens (or creates) cfg.Dir, replays any segments left over from
+// a previous run, and returns a ready-to-use durable sink.
+func NewWALSink[T any](cfg WALConfig, logger *zap.Logger) (*WALSink[T], error) {
+	cfg.init()
+
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("tracing: creating wal dir: %w", err)
+	}
+
+	w := &WALSink[T]{
+		cfg:       cfg,
+		logger:    logger,
+		lastFsync: time.Now(),
+	}
+
+	replayStart := time.Now()
+	items, err := w.replay()
+	if err != nil {
+		return nil, err
+	}
+	w.replayLag = time.Since(replayStart)
+
+	// Size the queue to fit whatever was replayed, not just cfg.BufferSize:
+	// an unclean shutdown (or a Processor spill) can easily leave more
+	// records on disk than the configured buffer holds, and pushing them
+	// with context.Background() into an undersized channel would block
+	// forever since nothing is draining it yet.
+	bufferSize := cfg.BufferSize
+	if len(items) > bufferSize {
+		bufferSize = len(items)
+	}
+	w.mem = NewMemorySink[T](bufferSize)
+
+	for _, item := range items {
+		if err := w.mem.Push(context.Background(), item); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(items) > 0 {
+		w.logger.Info("wal: replayed items from previous run",
+			zap.Int("items", len(items)),
+			zap.Duration("replay_lag", w.replayLag))
+	}
+
+	if err := w.rotate(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// ReplayLag reports how long replaying leftover segments took on startup.
+// Used to populate the wal_replay_lag metric.
+func (w *WALSink[T]) ReplayLag() time.Duration {
+	return w.replayLag
+}
+
+func (w *WALSink[T]) segmentPaths() ([]string, error) {
+	entries, err := os.ReadDir(w.cfg.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var paths []string
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".wal" {
+			continue
+		}
+		paths = append(paths, filepath.Join(w.cfg.Dir, e.Name()))
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// replay collects every record left over from a previous run into a single
+// in-memory slice, instead of pushing them onto w.mem directly: w.mem isn't
+// sized yet (NewWALSink needs the replayed count first), and pushing into a
+// fixed, possibly-undersized queue with no consumer running would risk
+// deadlocking startup.
+func (w *WALSink[T]) replay() ([]*T, error) {
+	paths, err := w.segmentPaths()
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*T
+	for _, path := range paths {
+		segItems, err := w.replaySegment(path)
+		if err != nil {
+			w.logger.Error("wal: failed to replay segment, skipping",
+				zap.String("path", path), zap.Error(err))
+			continue
+		}
+		items = append(items, segItems...)
+
+		if err := os.Remove(path); err != nil {
+			w.logger.Error("wal: failed to remove replayed segment", zap.String("path", path), zap.Error(err))
+		}
+	}
+	return items, nil
+}
+
+func (w *WALSink[T]) replaySegment(path string) ([]*T, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var items []*T
+	for {
+		item, err := readRecord[T](r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Partial/corrupt trailing record from a crash mid-write;
+			// stop replaying this segment but keep what we recovered.
+			break
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (w *WALSink[T]) rotate() error {
+	if w.active != nil {
+		if err := w.sealActive(); err != nil {
+			return err
+		}
+	}
+
+	path := filepath.Join(w.cfg.Dir, fmt.Sprintf("%d.wal", time.Now().UnixNano()))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("tracing: creating wal segment: %w", err)
+	}
+
+	w.active = f
+	w.activeW = bufio.NewWriter(f)
+	w.activePath = path
+	w.activeSize = 0
+	w.activeRecs = 0
+	return nil
+}
+
+func (w *WALSink[T]) sealActive() error {
+	if err := w.activeW.Flush(); err != nil {
+		return err
+	}
+	if err := w.active.Sync(); err != nil {
+		return err
+	}
+	if err := w.active.Close(); err != nil {
+		return err
+	}
+	records := w.activeRecs - w.activeAcked
+	switch {
+	case records > 0:
+		w.sealed = append(w.sealed, walSegment{path: w.activePath, records: records})
+		w.activeAcked = 0
+	case records == 0:
+		// Nothing left to replay, either because nothing was ever
+		// written or because Ack already covered everything in it.
+		os.Remove(w.activePath)
+		w.activeAcked = 0
+	default:
+		// Ack covered more than this segment held (a burst of acks
+		// landed right before rotation); carry the surplus forward so
+		// it's credited against the next segment instead of lost.
+		os.Remove(w.activePath)
+		w.activeAcked = -records
+	}
+	return nil
+}
+
+func writeRecord[T any](w *bufio.Writer, item *T) (int, error) {
+	b, err := json.Marshal(item)
+	if err != nil {
+		return 0, err
+	}
+
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(b)))
+	n, err := w.Write(length[:])
+	if err != nil {
+		return n, err
+	}
+	m, err := w.Write(b)
+	return n + m, err
+}
+
+func readRecord[T any](r *bufio.Reader) (*T, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, binary.BigEndian.Uint32(length[:]))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, io.EOF
+	}
+
+	var item T
+	if err := json.Unmarshal(buf, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+func (w *WALSink[T]) Push(ctx context.Context, item *T) error {
+	w.mu.Lock()
+
+	n, err := writeRecord[T](w.activeW, item)
+	if err != nil {
+		w.mu.Unlock()
+		return fmt.Errorf("tracing: wal append: %w", err)
+	}
+	w.activeSize += int64(n)
+	w.activeRecs++
+
+	switch w.cfg.FsyncPolicy {
+	case FsyncAlways:
+		err = w.flushAndSync()
+	case FsyncInterval:
+		if time.Since(w.lastFsync) >= w.cfg.FsyncInterval {
+			err = w.flushAndSync()
+		} else {
+			err = w.activeW.Flush()
+		}
+	}
+	if err != nil {
+		w.mu.Unlock()
+		return fmt.Errorf("tracing: wal fsync: %w", err)
+	}
+
+	if w.activeSize >= w.cfg.SegmentBytes {
+		if err := w.rotate(); err != nil {
+			w.mu.Unlock()
+			return err
+		}
+	}
+	w.mu.Unlock()
+
+	if err := w.mem.Push(ctx, item); err != nil {
+		// The record is already durably on disk at this point; it will be
+		// replayed on the next restart, so this is a delay, not a drop.
+		return ErrDelayed
+	}
+	return nil
+}
+
+func (w *WALSink[T]) flushAndSync() error {
+	if err := w.activeW.Flush(); err != nil {
+		return err
+	}
+	w.lastFsync = time.Now()
+	return w.active.Sync()
+}
+
+func (w *WALSink[T]) Pop(ctx context.Context) (*T, time.Time, bool) {
+	return w.mem.Pop(ctx)
+}
+
+func (w *WALSink[T]) Len() int {
+	return w.mem.Len()
+}
+
+// Ack tells the WAL that n previously-popped items have been durably
+// inserted downstream and can be reclaimed from disk. Sealed segments whose
+// records have all been acknowledged are deleted; the active segment is
+// never deleted since it is needed to recover from a crash before rotation,
+// but any budget left over after exhausting w.sealed is still credited
+// against it via activeAcked so sealActive can account for it once it does
+// rotate.
+func (w *WALSink[T]) Ack(n int) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for n > 0 && len(w.sealed) > 0 {
+		seg := w.sealed[0]
+		if seg.records > n {
+			w.sealed[0].records -= n
+			n = 0
+			break
+		}
+		n -= seg.records
+		if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+			w.logger.Error("wal: failed to remove acked segment", zap.String("path", seg.path), zap.Error(err))
+		}
+		w.sealed = w.sealed[1:]
+	}
+
+	w.activeAcked += n
+	return nil
+}
+
+func (w *WALSink[T]) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.sealActive()
+}
+
+// spillSegment durably writes items to a fresh segment file in dir using
+// the same length-prefixed JSON record format a WALSink reads on startup,
+// so pointing Processor.SetSpillDir at a WALSink's Dir makes these items
+// replay automatically the next time that WALSink is opened. Used by
+// Processor.Close as a last resort when a shutdown drain can't finish in
+// time.
+func spillSegment[T any](dir string, items []*T) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("tracing: creating spill dir: %w", err)
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("shutdown-%d.wal", time.Now().UnixNano()))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return "", fmt.Errorf("tracing: creating spill segment: %w", err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for _, item := range items {
+		if _, err := writeRecord(w, item); err != nil {
+			return "", fmt.Errorf("tracing: writing spilled item: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return "", fmt.Errorf("tracing: flushing spill segment: %w", err)
+	}
+	return path, f.Sync()
+}
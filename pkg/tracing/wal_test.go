@@ -0,0 +1,143 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type walTestItem struct {
+	Value int
+}
+
+func newTestWALSink(t *testing.T, cfg WALConfig) *WALSink[walTestItem] {
+	t.Helper()
+	cfg.Dir = t.TempDir()
+	w, err := NewWALSink[walTestItem](cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewWALSink: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = w.Close()
+	})
+	return w
+}
+
+func TestWALSinkRotateAndReplay(t *testing.T) {
+	const n = 50
+	cfg := WALConfig{SegmentBytes: 64, BufferSize: n}
+	w := newTestWALSink(t, cfg)
+	dir := w.cfg.Dir
+
+	for i := 0; i < n; i++ {
+		if err := w.Push(context.Background(), &walTestItem{Value: i}); err != nil {
+			t.Fatalf("Push(%d): %v", i, err)
+		}
+	}
+
+	if got := w.Len(); got != n {
+		t.Fatalf("Len() = %d, want %d", got, n)
+	}
+	if len(w.sealed) == 0 {
+		t.Fatal("expected at least one sealed segment given the tiny SegmentBytes")
+	}
+
+	// Simulate a crash: don't call Close (which would seal the active
+	// segment cleanly), just reopen a new WALSink against the same dir and
+	// confirm every pushed item comes back via replay.
+	w2cfg := WALConfig{SegmentBytes: 64, BufferSize: 10, Dir: dir}
+	w2, err := NewWALSink[walTestItem](w2cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewWALSink (reopen): %v", err)
+	}
+	defer w2.Close()
+
+	seen := make(map[int]bool)
+	for i := 0; i < n; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		item, _, ok := w2.Pop(ctx)
+		cancel()
+		if !ok {
+			t.Fatalf("Pop returned ok=false after only %d items replayed, want %d", i, n)
+		}
+		seen[item.Value] = true
+	}
+	if len(seen) != n {
+		t.Fatalf("replayed %d distinct items, want %d", len(seen), n)
+	}
+}
+
+func TestWALSinkReplayLargerThanBufferDoesNotDeadlock(t *testing.T) {
+	dir := t.TempDir()
+
+	cfg := WALConfig{SegmentBytes: 1 << 20, BufferSize: 1, Dir: dir}
+	w, err := NewWALSink[walTestItem](cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewWALSink: %v", err)
+	}
+
+	const n = 20
+	for i := 0; i < n; i++ {
+		if err := w.Push(context.Background(), &walTestItem{Value: i}); err != nil {
+			t.Fatalf("Push(%d): %v", i, err)
+		}
+	}
+	// Seal the active segment so the next NewWALSink has something to
+	// replay; the reopened sink's BufferSize (1) is far smaller than n, which is exactly the
+	// scenario that used to deadlock NewWALSink before it was fixed to
+	// size the queue to fit the replay.
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		w2, err := NewWALSink[walTestItem](WALConfig{SegmentBytes: 1 << 20, BufferSize: 1, Dir: dir}, zap.NewNop())
+		if err == nil {
+			defer w2.Close()
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("NewWALSink (reopen): %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("NewWALSink deadlocked replaying a backlog larger than BufferSize")
+	}
+}
+
+func TestWALSinkAckCreditsActiveSegment(t *testing.T) {
+	// A large SegmentBytes means every Push below lands in the same,
+	// still-active segment; Ack must credit that segment so a later
+	// rotation doesn't replay already-acked items.
+	cfg := WALConfig{SegmentBytes: 1 << 20, BufferSize: 10}
+	w := newTestWALSink(t, cfg)
+
+	const n = 5
+	for i := 0; i < n; i++ {
+		if err := w.Push(context.Background(), &walTestItem{Value: i}); err != nil {
+			t.Fatalf("Push(%d): %v", i, err)
+		}
+	}
+
+	if err := w.Ack(n); err != nil {
+		t.Fatalf("Ack: %v", err)
+	}
+	if w.activeAcked != n {
+		t.Fatalf("activeAcked = %d, want %d", w.activeAcked, n)
+	}
+
+	// Force rotation; sealActive should subtract the acked count instead
+	// of sealing the segment with its full, pre-ack record count.
+	if err := w.rotate(); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if len(w.sealed) != 0 {
+		t.Fatalf("sealed = %v, want no segments since every record was acked before rotation", w.sealed)
+	}
+}